@@ -0,0 +1,106 @@
+package godal
+
+import (
+	"context"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxParams is the number of bind parameters Postgres accepts in one statement.
+const maxParams = 65535
+
+// BatchOptions configures how CreateBatch and CreateOrUpdateBatch split their rows into statements.
+type BatchOptions struct {
+	// ChunkSize caps the number of rows per statement. Zero, or a size that
+	// would exceed the parameter limit, uses the largest chunk the column count allows.
+	ChunkSize int
+	// CommitPerChunk commits each chunk on its own instead of running all
+	// chunks in one transaction. On error the result counts the rows of the
+	// chunks already committed. Ignored on a transaction handle.
+	CommitPerChunk bool
+	// Columns is the explicit list of columns to insert. A row with a key
+	// outside the list is an error. Empty uses the union of the keys of all rows.
+	Columns []string
+	// MissingAsDefault writes DEFAULT for a column a row has no key for,
+	// instead of NULL, so the column default applies.
+	MissingAsDefault bool
+}
+
+func (o BatchOptions) chunkSize(numColumns int) int {
+	if numColumns < 1 {
+		numColumns = 1
+	}
+	size := maxParams / numColumns
+	if o.ChunkSize > 0 && o.ChunkSize < size {
+		size = o.ChunkSize
+	}
+	return size
+}
+
+// batchColumns returns the columns inserted for listMapData.
+func batchColumns(listMapData []map[string]interface{}, opts BatchOptions) ([]string, error) {
+	if len(opts.Columns) == 0 {
+		return getListColumns(listMapData), nil
+	}
+
+	known := make(map[string]bool, len(opts.Columns))
+	for _, col := range opts.Columns {
+		known[col] = true
+	}
+	for i, row := range listMapData {
+		for k := range row {
+			if !known[k] {
+				return nil, fmt.Errorf("godal: row %d has key %q that is not in the column list", i, k)
+			}
+		}
+	}
+	return opts.Columns, nil
+}
+
+// execChunks runs build for every chunk of listMapData. Several chunks run in
+// one transaction unless opts.CommitPerChunk is set.
+func (p *Postgres) execChunks(ctx context.Context, listMapData []map[string]interface{}, numColumns int, opts BatchOptions, build func(chunk []map[string]interface{}) (string, []interface{})) (*WriteResult, error) {
+	size := opts.chunkSize(numColumns)
+
+	var result *WriteResult
+	run := func(db *Postgres) error {
+		result = &WriteResult{}
+		if db.returning != nil {
+			result.Rows = make([]map[string]interface{}, 0)
+		}
+		for start := 0; start < len(listMapData); start += size {
+			end := start + size
+			if end > len(listMapData) {
+				end = len(listMapData)
+			}
+
+			sqlStatement, params := build(listMapData[start:end])
+			chunk, err := db.write(ctx, sqlStatement, params, nil)
+			if err != nil {
+				return err
+			}
+			result.add(chunk)
+		}
+		return nil
+	}
+
+	if len(listMapData) <= size || opts.CommitPerChunk || p.tx != nil {
+		if err := run(p); err != nil {
+			if opts.CommitPerChunk {
+				return result, err
+			}
+			return nil, err
+		}
+		return result, nil
+	}
+
+	err := p.WithTx(ctx, nil, func(tx IDatabase) error {
+		return run(tx.(*Postgres))
+	})
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return result, nil
+}
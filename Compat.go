@@ -0,0 +1,139 @@
+package godal
+
+import "context"
+
+// The write methods of IDatabase return interface{} for compatibility with
+// existing callers: a sql.Result, or the returned rows as
+// []map[string]interface{} on a Returning handle. They will be replaced by the
+// *WriteResult methods of Writer in the next major release.
+
+// Deprecated: use Writer().Create, which returns a *WriteResult.
+func (p *Postgres) Create(tableName string, mapData map[string]interface{}) (interface{}, error) {
+	return legacyResult(p.Writer().Create(tableName, mapData))
+}
+
+// Deprecated: use Writer().CreateContext, which returns a *WriteResult.
+func (p *Postgres) CreateContext(ctx context.Context, tableName string, mapData map[string]interface{}) (interface{}, error) {
+	return legacyResult(p.Writer().CreateContext(ctx, tableName, mapData))
+}
+
+// Deprecated: use Writer().CreateWithStruct, which returns a *WriteResult.
+func (p *Postgres) CreateWithStruct(tableName string, reqStruct interface{}) (interface{}, error) {
+	return legacyResult(p.Writer().CreateWithStruct(tableName, reqStruct))
+}
+
+// Deprecated: use Writer().CreateWithStructContext, which returns a *WriteResult.
+func (p *Postgres) CreateWithStructContext(ctx context.Context, tableName string, reqStruct interface{}) (interface{}, error) {
+	return legacyResult(p.Writer().CreateWithStructContext(ctx, tableName, reqStruct))
+}
+
+// Deprecated: use Writer().CreateOrUpdate, which returns a *WriteResult.
+func (p *Postgres) CreateOrUpdate(tableName string, reqStruct interface{}, primaryColumns []string) (interface{}, error) {
+	return legacyResult(p.Writer().CreateOrUpdate(tableName, reqStruct, primaryColumns))
+}
+
+// Deprecated: use Writer().CreateOrUpdateContext, which returns a *WriteResult.
+func (p *Postgres) CreateOrUpdateContext(ctx context.Context, tableName string, reqStruct interface{}, primaryColumns []string) (interface{}, error) {
+	return legacyResult(p.Writer().CreateOrUpdateContext(ctx, tableName, reqStruct, primaryColumns))
+}
+
+// Deprecated: use Writer().CreateOrUpdateWithConflict, which returns a *WriteResult.
+func (p *Postgres) CreateOrUpdateWithConflict(tableName string, reqStruct interface{}, conflict OnConflict) (interface{}, error) {
+	return legacyResult(p.Writer().CreateOrUpdateWithConflict(tableName, reqStruct, conflict))
+}
+
+// Deprecated: use Writer().CreateOrUpdateWithConflictContext, which returns a *WriteResult.
+func (p *Postgres) CreateOrUpdateWithConflictContext(ctx context.Context, tableName string, reqStruct interface{}, conflict OnConflict) (interface{}, error) {
+	return legacyResult(p.Writer().CreateOrUpdateWithConflictContext(ctx, tableName, reqStruct, conflict))
+}
+
+// Deprecated: use Writer().CreateBatch, which returns a *WriteResult.
+func (p *Postgres) CreateBatch(tableName string, listMapData []map[string]interface{}) (interface{}, error) {
+	return legacyResult(p.Writer().CreateBatch(tableName, listMapData))
+}
+
+// Deprecated: use Writer().CreateBatchContext, which returns a *WriteResult.
+func (p *Postgres) CreateBatchContext(ctx context.Context, tableName string, listMapData []map[string]interface{}) (interface{}, error) {
+	return legacyResult(p.Writer().CreateBatchContext(ctx, tableName, listMapData))
+}
+
+// Deprecated: use Writer().CreateBatchWithOptions, which returns a *WriteResult.
+func (p *Postgres) CreateBatchWithOptions(tableName string, listMapData []map[string]interface{}, opts BatchOptions) (interface{}, error) {
+	return legacyResult(p.Writer().CreateBatchWithOptions(tableName, listMapData, opts))
+}
+
+// Deprecated: use Writer().CreateBatchWithOptionsContext, which returns a *WriteResult.
+func (p *Postgres) CreateBatchWithOptionsContext(ctx context.Context, tableName string, listMapData []map[string]interface{}, opts BatchOptions) (interface{}, error) {
+	return legacyResult(p.Writer().CreateBatchWithOptionsContext(ctx, tableName, listMapData, opts))
+}
+
+// Deprecated: use Writer().CreateOrUpdateBatch, which returns a *WriteResult.
+func (p *Postgres) CreateOrUpdateBatch(tableName string, listMapData []map[string]interface{}, primaryBatch string) (interface{}, error) {
+	return legacyResult(p.Writer().CreateOrUpdateBatch(tableName, listMapData, primaryBatch))
+}
+
+// Deprecated: use Writer().CreateOrUpdateBatchContext, which returns a *WriteResult.
+func (p *Postgres) CreateOrUpdateBatchContext(ctx context.Context, tableName string, listMapData []map[string]interface{}, primaryBatch string) (interface{}, error) {
+	return legacyResult(p.Writer().CreateOrUpdateBatchContext(ctx, tableName, listMapData, primaryBatch))
+}
+
+// Deprecated: use Writer().CreateOrUpdateBatchWithOptions, which returns a *WriteResult.
+func (p *Postgres) CreateOrUpdateBatchWithOptions(tableName string, listMapData []map[string]interface{}, primaryBatch string, opts BatchOptions) (interface{}, error) {
+	return legacyResult(p.Writer().CreateOrUpdateBatchWithOptions(tableName, listMapData, primaryBatch, opts))
+}
+
+// Deprecated: use Writer().CreateOrUpdateBatchWithOptionsContext, which returns a *WriteResult.
+func (p *Postgres) CreateOrUpdateBatchWithOptionsContext(ctx context.Context, tableName string, listMapData []map[string]interface{}, primaryBatch string, opts BatchOptions) (interface{}, error) {
+	return legacyResult(p.Writer().CreateOrUpdateBatchWithOptionsContext(ctx, tableName, listMapData, primaryBatch, opts))
+}
+
+// Deprecated: use Writer().CreateOrUpdateBatchWithConflict, which returns a *WriteResult.
+func (p *Postgres) CreateOrUpdateBatchWithConflict(tableName string, listMapData []map[string]interface{}, conflict OnConflict, opts BatchOptions) (interface{}, error) {
+	return legacyResult(p.Writer().CreateOrUpdateBatchWithConflict(tableName, listMapData, conflict, opts))
+}
+
+// Deprecated: use Writer().CreateOrUpdateBatchWithConflictContext, which returns a *WriteResult.
+func (p *Postgres) CreateOrUpdateBatchWithConflictContext(ctx context.Context, tableName string, listMapData []map[string]interface{}, conflict OnConflict, opts BatchOptions) (interface{}, error) {
+	return legacyResult(p.Writer().CreateOrUpdateBatchWithConflictContext(ctx, tableName, listMapData, conflict, opts))
+}
+
+// Deprecated: use Writer().Update, which returns a *WriteResult.
+func (p *Postgres) Update(tableName string, newValue map[string]interface{}, whereCondition interface{}) (interface{}, error) {
+	return legacyResult(p.Writer().Update(tableName, newValue, whereCondition))
+}
+
+// Deprecated: use Writer().UpdateContext, which returns a *WriteResult.
+func (p *Postgres) UpdateContext(ctx context.Context, tableName string, newValue map[string]interface{}, whereCondition interface{}) (interface{}, error) {
+	return legacyResult(p.Writer().UpdateContext(ctx, tableName, newValue, whereCondition))
+}
+
+// Deprecated: use Writer().Delete, which returns a *WriteResult.
+func (p *Postgres) Delete(tableName string, whereCondition interface{}) (interface{}, error) {
+	return legacyResult(p.Writer().Delete(tableName, whereCondition))
+}
+
+// Deprecated: use Writer().DeleteContext, which returns a *WriteResult.
+func (p *Postgres) DeleteContext(ctx context.Context, tableName string, whereCondition interface{}) (interface{}, error) {
+	return legacyResult(p.Writer().DeleteContext(ctx, tableName, whereCondition))
+}
+
+// Deprecated: use Writer().Execute, which returns a *WriteResult.
+func (p *Postgres) Execute(sqlExecute string, params []interface{}) (interface{}, error) {
+	return legacyResult(p.Writer().Execute(sqlExecute, params))
+}
+
+// Deprecated: use Writer().ExecuteContext, which returns a *WriteResult.
+func (p *Postgres) ExecuteContext(ctx context.Context, sqlExecute string, params []interface{}) (interface{}, error) {
+	return legacyResult(p.Writer().ExecuteContext(ctx, sqlExecute, params))
+}
+
+// legacyResult converts a *WriteResult to the former interface{} result.
+func legacyResult(result *WriteResult, err error) (interface{}, error) {
+	if result == nil {
+		return nil, err
+	}
+	if result.Rows != nil {
+		return result.Rows, err
+	}
+	return result, err
+}
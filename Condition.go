@@ -0,0 +1,402 @@
+package godal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrEmptyCondition = errors.New("godal: empty where condition")
+)
+
+// Condition is a WHERE clause built with Eq, In, Between, And, Or and the
+// other helpers of this file. Update and Delete accept a Condition, a Where
+// or a plain map[string]interface{}.
+type Condition interface {
+	// toSQL registers the condition parameters in args and returns its SQL.
+	toSQL(args *queryArgs) (string, error)
+}
+
+// queryArgs collects the parameters of a statement and numbers their
+// placeholders. It also carries the identifier quoting of the statement.
+type queryArgs struct {
+	values []interface{}
+	idents identQuoter
+}
+
+// add registers v as the next parameter and returns its placeholder.
+// A *SelectBuilder is rendered inline as a parenthesised sub-query.
+func (a *queryArgs) add(v interface{}) (string, error) {
+	if sub, ok := v.(*SelectBuilder); ok {
+		subSQL, err := sub.build(a)
+		if err != nil {
+			return "", err
+		}
+		return "(" + subSQL + ")", nil
+	}
+
+	if reflect.ValueOf(v).Kind() == reflect.Map || reflect.ValueOf(v).Kind() == reflect.Array {
+		v, _ = json.Marshal(v)
+	}
+	a.values = append(a.values, v)
+	return fmt.Sprintf("$%d", len(a.values)), nil
+}
+
+// Where is a set of column = value conditions joined by AND.
+// A nil value compiles to IS NULL.
+type Where map[string]interface{}
+
+func (w Where) toSQL(args *queryArgs) (string, error) {
+	columns := make([]string, 0, len(w))
+	for k := range w {
+		columns = append(columns, k)
+	}
+	sort.Strings(columns)
+
+	conds := make([]Condition, 0, len(columns))
+	for _, col := range columns {
+		conds = append(conds, Eq(col, w[col]))
+	}
+	return And(conds...).toSQL(args)
+}
+
+type compareCond struct {
+	column string
+	op     string
+	value  interface{}
+}
+
+func (c compareCond) toSQL(args *queryArgs) (string, error) {
+	col, err := args.idents.quote(c.column)
+	if err != nil {
+		return "", err
+	}
+	placeholder, err := args.add(c.value)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s %s", col, c.op, placeholder), nil
+}
+
+// Eq compiles to column = value, or column IS NULL when value is nil.
+func Eq(column string, value interface{}) Condition {
+	if value == nil {
+		return IsNull(column)
+	}
+	return compareCond{column, "=", value}
+}
+
+// Neq compiles to column <> value, or column IS NOT NULL when value is nil.
+func Neq(column string, value interface{}) Condition {
+	if value == nil {
+		return IsNotNull(column)
+	}
+	return compareCond{column, "<>", value}
+}
+
+// Gt compiles to column > value.
+func Gt(column string, value interface{}) Condition {
+	return compareCond{column, ">", value}
+}
+
+// Gte compiles to column >= value.
+func Gte(column string, value interface{}) Condition {
+	return compareCond{column, ">=", value}
+}
+
+// Lt compiles to column < value.
+func Lt(column string, value interface{}) Condition {
+	return compareCond{column, "<", value}
+}
+
+// Lte compiles to column <= value.
+func Lte(column string, value interface{}) Condition {
+	return compareCond{column, "<=", value}
+}
+
+// Like compiles to column LIKE pattern.
+func Like(column string, pattern string) Condition {
+	return compareCond{column, "LIKE", pattern}
+}
+
+// ILike compiles to column ILIKE pattern.
+func ILike(column string, pattern string) Condition {
+	return compareCond{column, "ILIKE", pattern}
+}
+
+type nullCond struct {
+	column string
+	not    bool
+}
+
+func (c nullCond) toSQL(args *queryArgs) (string, error) {
+	col, err := args.idents.quote(c.column)
+	if err != nil {
+		return "", err
+	}
+	if c.not {
+		return col + " IS NOT NULL", nil
+	}
+	return col + " IS NULL", nil
+}
+
+// IsNull compiles to column IS NULL.
+func IsNull(column string) Condition {
+	return nullCond{column: column}
+}
+
+// IsNotNull compiles to column IS NOT NULL.
+func IsNotNull(column string) Condition {
+	return nullCond{column: column, not: true}
+}
+
+type inCond struct {
+	column string
+	values []interface{}
+	not    bool
+}
+
+func (c inCond) toSQL(args *queryArgs) (string, error) {
+	col, err := args.idents.quote(c.column)
+	if err != nil {
+		return "", err
+	}
+
+	if len(c.values) == 0 {
+		// Nothing is IN an empty list, and everything is NOT IN it.
+		if c.not {
+			return "TRUE", nil
+		}
+		return "FALSE", nil
+	}
+
+	op := "IN"
+	if c.not {
+		op = "NOT IN"
+	}
+
+	if sub, ok := c.values[0].(*SelectBuilder); ok && len(c.values) == 1 {
+		subSQL, err := sub.build(args)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s %s (%s)", col, op, subSQL), nil
+	}
+
+	placeholders := make([]string, len(c.values))
+	for i, v := range c.values {
+		placeholder, err := args.add(v)
+		if err != nil {
+			return "", err
+		}
+		placeholders[i] = placeholder
+	}
+
+	return fmt.Sprintf("%s %s (%s)", col, op, strings.Join(placeholders, ", ")), nil
+}
+
+// In compiles to column IN (values...). A single slice argument is expanded
+// and a single *SelectBuilder becomes column IN (sub-query).
+func In(column string, values ...interface{}) Condition {
+	return inCond{column: column, values: expandValues(values)}
+}
+
+// NotIn compiles to column NOT IN (values...). A single slice argument is expanded.
+func NotIn(column string, values ...interface{}) Condition {
+	return inCond{column: column, values: expandValues(values), not: true}
+}
+
+func expandValues(values []interface{}) []interface{} {
+	if len(values) != 1 {
+		return values
+	}
+
+	v := reflect.ValueOf(values[0])
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 {
+		return values
+	}
+
+	expanded := make([]interface{}, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		expanded[i] = v.Index(i).Interface()
+	}
+	return expanded
+}
+
+type betweenCond struct {
+	column string
+	from   interface{}
+	to     interface{}
+}
+
+func (c betweenCond) toSQL(args *queryArgs) (string, error) {
+	col, err := args.idents.quote(c.column)
+	if err != nil {
+		return "", err
+	}
+	from, err := args.add(c.from)
+	if err != nil {
+		return "", err
+	}
+	to, err := args.add(c.to)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s BETWEEN %s AND %s", col, from, to), nil
+}
+
+// Between compiles to column BETWEEN from AND to.
+func Between(column string, from interface{}, to interface{}) Condition {
+	return betweenCond{column, from, to}
+}
+
+type groupCond struct {
+	op    string
+	conds []Condition
+}
+
+func (c groupCond) toSQL(args *queryArgs) (string, error) {
+	if len(c.conds) == 0 {
+		return "", ErrEmptyCondition
+	}
+
+	parts := make([]string, 0, len(c.conds))
+	for _, cond := range c.conds {
+		part, err := cond.toSQL(args)
+		if err != nil {
+			return "", err
+		}
+		if len(c.conds) > 1 && compound(cond) {
+			part = "(" + part + ")"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, " "+c.op+" "), nil
+}
+
+// compound reports whether cond may render several predicates, which must be
+// parenthesised inside a group to keep their precedence.
+func compound(cond Condition) bool {
+	switch c := cond.(type) {
+	case groupCond, rawCond, notCond:
+		return true
+	case Where:
+		return len(c) > 1
+	}
+	return false
+}
+
+// And joins conditions with AND, nested groups, Raw, Not and Where with
+// several columns are parenthesised.
+func And(conds ...Condition) Condition {
+	return groupCond{"AND", conds}
+}
+
+// Or joins conditions with OR, parenthesising compound conditions like And.
+func Or(conds ...Condition) Condition {
+	return groupCond{"OR", conds}
+}
+
+type notCond struct {
+	cond Condition
+}
+
+func (c notCond) toSQL(args *queryArgs) (string, error) {
+	part, err := c.cond.toSQL(args)
+	if err != nil {
+		return "", err
+	}
+	return "NOT (" + part + ")", nil
+}
+
+// Not negates a condition.
+func Not(cond Condition) Condition {
+	return notCond{cond}
+}
+
+type existsCond struct {
+	sub *SelectBuilder
+}
+
+func (c existsCond) toSQL(args *queryArgs) (string, error) {
+	subSQL, err := c.sub.build(args)
+	if err != nil {
+		return "", err
+	}
+	return "EXISTS (" + subSQL + ")", nil
+}
+
+// Exists compiles to EXISTS (sub-query).
+func Exists(sub *SelectBuilder) Condition {
+	return existsCond{sub}
+}
+
+type rawCond struct {
+	sql    string
+	values []interface{}
+}
+
+func (c rawCond) toSQL(args *queryArgs) (string, error) {
+	var sb strings.Builder
+	n := 0
+	for i := 0; i < len(c.sql); i++ {
+		if c.sql[i] != '?' {
+			sb.WriteByte(c.sql[i])
+			continue
+		}
+		if i+1 < len(c.sql) && c.sql[i+1] == '?' {
+			sb.WriteByte('?')
+			i++
+			continue
+		}
+		if n >= len(c.values) {
+			n++
+			continue
+		}
+		placeholder, err := args.add(c.values[n])
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(placeholder)
+		n++
+	}
+	if n != len(c.values) {
+		return "", fmt.Errorf("godal: raw condition %q has %d placeholders but %d values", c.sql, n, len(c.values))
+	}
+	return sb.String(), nil
+}
+
+// Raw is an SQL condition written by hand, each ? is replaced by the
+// placeholder of the matching value, e.g. Raw("count(*) > ?", 5). Write ?? for
+// a literal ?, such as the jsonb operators: Raw("data ?? ?", "k") compiles to
+// data ? $1, and ??| and ??& to ?| and ?&.
+func Raw(sql string, values ...interface{}) Condition {
+	return rawCond{sql, values}
+}
+
+// toCondition accepts the where arguments of Update and Delete.
+func toCondition(whereCondition interface{}) (Condition, error) {
+	switch cond := whereCondition.(type) {
+	case Condition:
+		return cond, nil
+	case map[string]interface{}:
+		return Where(cond), nil
+	case nil:
+		return nil, ErrEmptyCondition
+	default:
+		return nil, fmt.Errorf("godal: unsupported where condition type %T", whereCondition)
+	}
+}
+
+// buildWhere compiles whereCondition into args and returns the WHERE clause body.
+func buildWhere(whereCondition interface{}, args *queryArgs) (string, error) {
+	cond, err := toCondition(whereCondition)
+	if err != nil {
+		return "", err
+	}
+	return cond.toSQL(args)
+}
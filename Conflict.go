@@ -0,0 +1,176 @@
+package godal
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MergeStrategy is how a column is updated from the proposed row on conflict.
+type MergeStrategy int
+
+const (
+	// MergeOverwrite takes the proposed value.
+	MergeOverwrite MergeStrategy = iota
+	// MergeKeepExisting keeps the existing value, taking the proposed one only when it is NULL.
+	MergeKeepExisting
+	// MergeGreatest keeps the greatest of the existing and proposed values.
+	MergeGreatest
+	// MergeLeast keeps the least of the existing and proposed values.
+	MergeLeast
+	// MergeAdd adds the proposed value to the existing one, for counters.
+	MergeAdd
+)
+
+// expr returns the SET expression of the column col of table.
+func (m MergeStrategy) expr(table, col string) (string, error) {
+	switch m {
+	case MergeOverwrite:
+		return "EXCLUDED." + col, nil
+	case MergeKeepExisting:
+		return fmt.Sprintf("COALESCE(%s.%s, EXCLUDED.%s)", table, col, col), nil
+	case MergeGreatest:
+		return fmt.Sprintf("GREATEST(%s.%s, EXCLUDED.%s)", table, col, col), nil
+	case MergeLeast:
+		return fmt.Sprintf("LEAST(%s.%s, EXCLUDED.%s)", table, col, col), nil
+	case MergeAdd:
+		return fmt.Sprintf("COALESCE(%s.%s, 0) + EXCLUDED.%s", table, col, col), nil
+	}
+	return "", fmt.Errorf("godal: unknown merge strategy %d", m)
+}
+
+// OnConflict describes the ON CONFLICT clause of CreateOrUpdate and CreateOrUpdateBatch.
+type OnConflict struct {
+	// Columns is the conflict target: the columns of a unique index or primary key.
+	Columns []string
+	// Constraint is a conflict target given by name, ON CONFLICT ON CONSTRAINT.
+	// It can not be combined with Columns.
+	Constraint string
+	// Where is the predicate of a partial unique index, written as raw SQL,
+	// e.g. "deleted_at IS NULL". It requires Columns.
+	Where string
+	// DoNothing skips the conflicting rows instead of updating them.
+	DoNothing bool
+	// Update is the list of columns set from the proposed row on conflict. Empty
+	// updates every inserted column outside the conflict target.
+	Update []string
+	// Merge sets the strategy of updated columns, the others are overwritten.
+	Merge map[string]MergeStrategy
+	// OnlyIfChanged skips the update when it would not change the row, so no
+	// new row version is written and update triggers do not fire.
+	OnlyIfChanged bool
+}
+
+// conflictColumns is the OnConflict targeting the comma separated column list of primaryColumns.
+func conflictColumns(primaryColumns string) OnConflict {
+	columns := strings.Split(primaryColumns, ",")
+	for i := range columns {
+		columns[i] = strings.TrimSpace(columns[i])
+	}
+	return OnConflict{Columns: columns}
+}
+
+// toSQL renders the ON CONFLICT clause for an INSERT of insertColumns into
+// the quoted strTable. updatable filters the columns of the default update list.
+func (c OnConflict) toSQL(idents identQuoter, strTable string, insertColumns []string, updatable func(col string) bool) (string, error) {
+	if len(c.Columns) > 0 && c.Constraint != "" {
+		return "", errors.New("godal: OnConflict takes Columns or Constraint, not both")
+	}
+	if c.Where != "" && len(c.Columns) == 0 {
+		return "", errors.New("godal: OnConflict.Where needs the Columns of the partial index")
+	}
+	if c.DoNothing && len(c.Update) > 0 {
+		return "", errors.New("godal: OnConflict takes DoNothing or Update, not both")
+	}
+
+	strTarget := ""
+	switch {
+	case len(c.Columns) > 0:
+		strColumns, err := idents.quoteColumns(c.Columns)
+		if err != nil {
+			return "", err
+		}
+		strTarget = " (" + strColumns + ")"
+		if c.Where != "" {
+			strTarget = strTarget + " WHERE " + c.Where
+		}
+	case c.Constraint != "":
+		strConstraint, err := idents.quote(c.Constraint)
+		if err != nil {
+			return "", err
+		}
+		strTarget = " ON CONSTRAINT " + strConstraint
+	}
+
+	listUpdate, err := c.updateColumns(insertColumns, updatable)
+	if err != nil {
+		return "", err
+	}
+	if c.DoNothing || len(listUpdate) == 0 {
+		return "ON CONFLICT" + strTarget + " DO NOTHING", nil
+	}
+	if strTarget == "" {
+		return "", errors.New("godal: OnConflict needs Columns or Constraint to update")
+	}
+
+	updated := make(map[string]bool, len(listUpdate))
+	listSet := make([]string, len(listUpdate))
+	listOld := make([]string, len(listUpdate))
+	listNew := make([]string, len(listUpdate))
+	for i, col := range listUpdate {
+		updated[col] = true
+		quotedCol, err := idents.quoteColumn(col)
+		if err != nil {
+			return "", err
+		}
+		expr, err := c.Merge[col].expr(strTable, quotedCol)
+		if err != nil {
+			return "", err
+		}
+		listSet[i] = quotedCol + " = " + expr
+		listOld[i] = strTable + "." + quotedCol
+		listNew[i] = expr
+	}
+	for col := range c.Merge {
+		if !updated[col] {
+			return "", fmt.Errorf("godal: merge column %q is not updated", col)
+		}
+	}
+
+	strConflict := "ON CONFLICT" + strTarget + " DO UPDATE SET " + strings.Join(listSet, ", ")
+	if c.OnlyIfChanged {
+		// Compare with the values the update would write, so merged columns
+		// only count as changed when the merge changes them.
+		strConflict = fmt.Sprintf("%s WHERE (%s) IS DISTINCT FROM (%s)", strConflict,
+			strings.Join(listOld, ", "), strings.Join(listNew, ", "))
+	}
+	return strConflict, nil
+}
+
+func (c OnConflict) updateColumns(insertColumns []string, updatable func(col string) bool) ([]string, error) {
+	inserted := make(map[string]bool, len(insertColumns))
+	for _, col := range insertColumns {
+		inserted[col] = true
+	}
+
+	if len(c.Update) > 0 {
+		for _, col := range c.Update {
+			if !inserted[col] {
+				return nil, fmt.Errorf("godal: update column %q is not inserted", col)
+			}
+		}
+		return c.Update, nil
+	}
+
+	target := make(map[string]bool, len(c.Columns))
+	for _, col := range c.Columns {
+		target[col] = true
+	}
+	listUpdate := make([]string, 0, len(insertColumns))
+	for _, col := range insertColumns {
+		if !target[col] && (updatable == nil || updatable(col)) {
+			listUpdate = append(listUpdate, col)
+		}
+	}
+	return listUpdate, nil
+}
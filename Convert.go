@@ -0,0 +1,217 @@
+package godal
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	scannerType    = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+	timeType       = reflect.TypeOf(time.Time{})
+)
+
+// scannerTypes caches whether a pointer to a type implements sql.Scanner,
+// reflect's Implements being too slow to run for every column of every row.
+var scannerTypes sync.Map
+
+func isScanner(t reflect.Type) bool {
+	if ok, found := scannerTypes.Load(t); found {
+		return ok.(bool)
+	}
+	ok := reflect.PtrTo(t).Implements(scannerType)
+	scannerTypes.Store(t, ok)
+	return ok
+}
+
+// convertAssign stores the driver value src in dst, converting between the
+// types lib/pq returns (int64, float64, bool, []byte, string, time.Time) and
+// the type of the field. NULL sets the zero value, nil for pointers.
+func convertAssign(dst reflect.Value, src interface{}) error {
+	if dst.CanAddr() && isScanner(dst.Type()) {
+		return dst.Addr().Interface().(sql.Scanner).Scan(src)
+	}
+
+	if src == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+
+	if dst.Kind() == reflect.Ptr {
+		elem := reflect.New(dst.Type().Elem())
+		if err := convertAssign(elem.Elem(), src); err != nil {
+			return err
+		}
+		dst.Set(elem)
+		return nil
+	}
+
+	srcVal := reflect.ValueOf(src)
+	if dst.Kind() == reflect.Interface || (srcVal.Type().AssignableTo(dst.Type()) && dst.Type() != rawMessageType) {
+		if b, ok := src.([]byte); ok {
+			// The driver reuses its buffer, keep a copy.
+			src = append([]byte(nil), b...)
+		}
+		dst.Set(reflect.ValueOf(src))
+		return nil
+	}
+
+	switch v := src.(type) {
+	case []byte:
+		return convertText(dst, v)
+	case string:
+		return convertText(dst, []byte(v))
+	case int64:
+		return convertInt(dst, v)
+	case float64:
+		return convertFloat(dst, v)
+	case bool:
+		switch dst.Kind() {
+		case reflect.Bool:
+			dst.SetBool(v)
+			return nil
+		case reflect.String:
+			dst.SetString(strconv.FormatBool(v))
+			return nil
+		}
+	case time.Time:
+		if dst.Kind() == reflect.String {
+			dst.SetString(v.Format(time.RFC3339Nano))
+			return nil
+		}
+	}
+
+	if srcVal.Type().ConvertibleTo(dst.Type()) && srcVal.Kind() == dst.Kind() {
+		dst.Set(srcVal.Convert(dst.Type()))
+		return nil
+	}
+
+	return fmt.Errorf("cannot convert %T to %s", src, dst.Type())
+}
+
+// convertJSON decodes a json or jsonb column into dst, for fields tagged json.
+func convertJSON(dst reflect.Value, src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dst.Addr().Interface())
+	case string:
+		return json.Unmarshal([]byte(v), dst.Addr().Interface())
+	}
+	return convertAssign(dst, src)
+}
+
+// convertText handles the text and bytea values, which also carry numeric,
+// json and jsonb columns.
+func convertText(dst reflect.Value, b []byte) error {
+	if dst.Type() == rawMessageType {
+		dst.SetBytes(append([]byte(nil), b...))
+		return nil
+	}
+
+	switch dst.Kind() {
+	case reflect.String:
+		dst.SetString(string(b))
+		return nil
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes(append([]byte(nil), b...))
+			return nil
+		}
+	case reflect.Bool:
+		v, err := strconv.ParseBool(string(b))
+		if err != nil {
+			return err
+		}
+		dst.SetBool(v)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(string(b), 10, 64)
+		if err != nil {
+			return err
+		}
+		return convertInt(dst, v)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(string(b), 10, 64)
+		if err != nil {
+			return err
+		}
+		if dst.OverflowUint(v) {
+			return fmt.Errorf("value %d overflows %s", v, dst.Type())
+		}
+		dst.SetUint(v)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(string(b), 64)
+		if err != nil {
+			return err
+		}
+		return convertFloat(dst, v)
+	}
+
+	if dst.Type() == timeType {
+		return fmt.Errorf("cannot parse %q as %s", b, dst.Type())
+	}
+
+	switch dst.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array:
+		return json.Unmarshal(b, dst.Addr().Interface())
+	}
+
+	return fmt.Errorf("cannot convert []byte to %s", dst.Type())
+}
+
+func convertInt(dst reflect.Value, v int64) error {
+	switch dst.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if dst.OverflowInt(v) {
+			return fmt.Errorf("value %d overflows %s", v, dst.Type())
+		}
+		dst.SetInt(v)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v < 0 || dst.OverflowUint(uint64(v)) {
+			return fmt.Errorf("value %d overflows %s", v, dst.Type())
+		}
+		dst.SetUint(uint64(v))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		dst.SetFloat(float64(v))
+		return nil
+	case reflect.String:
+		dst.SetString(strconv.FormatInt(v, 10))
+		return nil
+	case reflect.Bool:
+		dst.SetBool(v != 0)
+		return nil
+	}
+	return fmt.Errorf("cannot convert int64 to %s", dst.Type())
+}
+
+func convertFloat(dst reflect.Value, v float64) error {
+	switch dst.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if dst.OverflowFloat(v) {
+			return fmt.Errorf("value %g overflows %s", v, dst.Type())
+		}
+		dst.SetFloat(v)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v != float64(int64(v)) {
+			return fmt.Errorf("value %g is not an integer", v)
+		}
+		return convertInt(dst, int64(v))
+	case reflect.String:
+		dst.SetString(strconv.FormatFloat(v, 'f', -1, 64))
+		return nil
+	}
+	return fmt.Errorf("cannot convert float64 to %s", dst.Type())
+}
@@ -0,0 +1,227 @@
+package godal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	copyLinePattern = regexp.MustCompile(`line (\d+)`)
+)
+
+// CopySource feeds CopyFrom one row at a time, for loads that do not fit in a slice.
+type CopySource interface {
+	// Next advances to the next row and returns false when there is none left.
+	Next() bool
+	// Values returns the values of the current row, in the order of the columns.
+	Values() ([]interface{}, error)
+	// Err returns the error that stopped Next, if any.
+	Err() error
+}
+
+// CopyFrom bulk loads rows into tableName with COPY FROM STDIN and returns
+// the number of rows loaded. rows is a []map[string]interface{}, a slice of
+// structs or struct pointers, or a CopySource. When columns is empty it is
+// the union of the map keys, or the insertable fields of the struct; it is
+// required for a CopySource.
+//
+// The load runs in the transaction of a transaction-scoped handle, otherwise
+// in a transaction of its own: either all rows are loaded or none.
+func (p *Postgres) CopyFrom(ctx context.Context, tableName string, columns []string, rows interface{}) (int64, error) {
+	source, columns, err := newCopySource(rows, columns)
+	if err != nil {
+		log.Error(err)
+		return 0, err
+	}
+
+	copySQL, err := copyStatement(p.identQuoter(), tableName, columns)
+	if err != nil {
+		log.Error(err)
+		return 0, err
+	}
+
+	var count int64
+	copyRows := func(tx IDatabase) error {
+		count, err = tx.(*Postgres).copyIn(ctx, copySQL, columns, source)
+		return err
+	}
+
+	if p.tx != nil {
+		err = copyRows(p)
+	} else {
+		err = p.WithTx(ctx, nil, copyRows)
+	}
+	if err != nil {
+		log.Error(err)
+		return 0, err
+	}
+	return count, nil
+}
+
+func (p *Postgres) copyIn(ctx context.Context, copySQL string, columns []string, source CopySource) (int64, error) {
+	stmt, err := p.tx.tx.PrepareContext(ctx, copySQL)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	row := 0
+	for ; source.Next(); row++ {
+		values, err := source.Values()
+		if err == nil && len(values) != len(columns) {
+			err = fmt.Errorf("%d values for %d columns", len(values), len(columns))
+		}
+		if err != nil {
+			return 0, &CopyError{Row: row, Err: err}
+		}
+
+		if _, err = stmt.ExecContext(ctx, values...); err != nil {
+			return 0, copyError(row, err)
+		}
+	}
+	if err = source.Err(); err != nil {
+		return 0, &CopyError{Row: row, Err: err}
+	}
+
+	rs, err := stmt.ExecContext(ctx)
+	if err != nil {
+		return 0, copyError(row, err)
+	}
+	return rs.RowsAffected()
+}
+
+// copyStatement builds the COPY FROM STDIN of columns into tableName, which is
+// a table name optionally qualified by its schema. The names are checked
+// against the strict identifier allowlist like the other write paths.
+func copyStatement(idents identQuoter, tableName string, columns []string) (string, error) {
+	if _, _, err := quoteTableAndColumns(idents, tableName, columns); err != nil {
+		return "", err
+	}
+
+	parts, err := splitIdentifier(tableName)
+	if err != nil {
+		return "", err
+	}
+
+	// pq.CopyIn and pq.CopyInSchema quote the names themselves.
+	switch len(parts) {
+	case 1:
+		return pq.CopyIn(parts[0], columns...), nil
+	case 2:
+		return pq.CopyInSchema(parts[0], parts[1], columns...), nil
+	}
+	return "", fmt.Errorf("godal: CopyFrom needs a table name or schema.table, got %q", tableName)
+}
+
+// copyError locates the failing row. The server reports errors after it
+// received the data, so the line of the COPY context is preferred over the
+// row being sent when the error came back.
+func copyError(row int, err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		if m := copyLinePattern.FindStringSubmatch(pqErr.Where); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			row = line - 1
+		}
+	}
+	return &CopyError{Row: row, Err: err}
+}
+
+func newCopySource(rows interface{}, columns []string) (CopySource, []string, error) {
+	switch src := rows.(type) {
+	case CopySource:
+		if len(columns) == 0 {
+			return nil, nil, errors.New("godal: CopyFrom needs the columns of a CopySource")
+		}
+		return src, columns, nil
+	case []map[string]interface{}:
+		if len(columns) == 0 {
+			columns = getListColumns(src)
+		}
+		return &mapCopySource{rows: src, columns: columns, index: -1}, columns, nil
+	}
+
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice || indirectType(v.Type().Elem()).Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("godal: CopyFrom does not support rows of type %T", rows)
+	}
+
+	plan := planOf(indirectType(v.Type().Elem()))
+	fields := make([]structField, 0, len(plan.fields))
+	if len(columns) == 0 {
+		for _, field := range plan.fields {
+			if field.insertable() {
+				fields = append(fields, field)
+			}
+		}
+		columns = columnsOf(fields)
+	} else {
+		for _, col := range columns {
+			field, ok := plan.byColumn[col]
+			if !ok {
+				return nil, nil, fmt.Errorf("godal: %s has no field for column %q", v.Type().Elem(), col)
+			}
+			fields = append(fields, *field)
+		}
+	}
+	return &structCopySource{rows: v, fields: fields, index: -1}, columns, nil
+}
+
+type mapCopySource struct {
+	rows    []map[string]interface{}
+	columns []string
+	index   int
+}
+
+func (s *mapCopySource) Next() bool {
+	s.index++
+	return s.index < len(s.rows)
+}
+
+func (s *mapCopySource) Values() ([]interface{}, error) {
+	values := make([]interface{}, len(s.columns))
+	for i, col := range s.columns {
+		values[i] = paramValue(reflect.ValueOf(s.rows[s.index][col]))
+	}
+	return values, nil
+}
+
+func (s *mapCopySource) Err() error {
+	return nil
+}
+
+type structCopySource struct {
+	rows   reflect.Value
+	fields []structField
+	index  int
+}
+
+func (s *structCopySource) Next() bool {
+	s.index++
+	return s.index < s.rows.Len()
+}
+
+func (s *structCopySource) Values() ([]interface{}, error) {
+	row := reflect.Indirect(s.rows.Index(s.index))
+	if !row.IsValid() {
+		return nil, errors.New("nil struct pointer")
+	}
+
+	values := make([]interface{}, len(s.fields))
+	for i, field := range s.fields {
+		field.omitEmpty = false
+		values[i], _ = fieldParam(row, field)
+	}
+	return values, nil
+}
+
+func (s *structCopySource) Err() error {
+	return nil
+}
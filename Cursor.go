@@ -0,0 +1,144 @@
+package godal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrCursorClosed = errors.New("godal: cursor is closed")
+
+	cursorSeq uint64
+)
+
+// Cursor is a server-side cursor declared with DECLARE ... CURSOR, read in
+// batches with FETCH so that the client only ever holds one batch in memory.
+// A cursor lives inside a transaction: on a transaction-scoped handle it uses
+// that transaction, otherwise it begins its own and commits it on Close.
+type Cursor struct {
+	tx     *Postgres
+	ownTx  bool
+	name   string
+	done   bool
+	closed bool
+}
+
+// DeclareCursor declares a cursor for sqlQuery. Always Close the cursor.
+func (p *Postgres) DeclareCursor(ctx context.Context, sqlQuery string, params []interface{}) (*Cursor, error) {
+	cursor := &Cursor{name: fmt.Sprintf("godal_cursor_%d", atomic.AddUint64(&cursorSeq, 1))}
+
+	if p.tx != nil {
+		cursor.tx = p
+	} else {
+		tx, err := p.Begin(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		cursor.tx = tx.(*Postgres)
+		cursor.ownTx = true
+	}
+
+	sqlStatement := fmt.Sprintf("DECLARE %s NO SCROLL CURSOR FOR %s", cursor.name, sqlQuery)
+	if _, err := cursor.tx.exec(ctx, sqlStatement, params); err != nil {
+		if cursor.ownTx {
+			cursor.tx.Rollback()
+		}
+		return nil, err
+	}
+
+	return cursor, nil
+}
+
+// FetchMap returns the next batch of at most n rows as maps, an empty batch
+// once the cursor is exhausted.
+func (c *Cursor) FetchMap(ctx context.Context, n int) ([]map[string]interface{}, error) {
+	batch := []map[string]interface{}{}
+	err := c.forEach(ctx, n, 1, func(r *Rows) error {
+		batch = append(batch, r.Map())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// FetchStruct returns the next batch of at most n rows as pointers to new
+// copies of respStruct, an empty batch once the cursor is exhausted.
+func (c *Cursor) FetchStruct(ctx context.Context, n int, respStruct interface{}) ([]interface{}, error) {
+	batch := []interface{}{}
+	err := c.forEach(ctx, n, 1, structRowFunc(respStruct, func(row interface{}) error {
+		batch = append(batch, row)
+		return nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// ForEachMap fetches batchSize rows at a time and calls fn with every row as
+// a map, until the cursor is exhausted or fn fails.
+func (c *Cursor) ForEachMap(ctx context.Context, batchSize int, fn func(row map[string]interface{}) error) error {
+	return c.forEach(ctx, batchSize, 0, func(r *Rows) error {
+		return fn(r.Map())
+	})
+}
+
+// ForEachStruct fetches batchSize rows at a time and calls fn with every row
+// as a pointer to a new copy of respStruct, until the cursor is exhausted or fn fails.
+func (c *Cursor) ForEachStruct(ctx context.Context, batchSize int, respStruct interface{}, fn func(row interface{}) error) error {
+	return c.forEach(ctx, batchSize, 0, structRowFunc(respStruct, fn))
+}
+
+// forEach reads maxBatches batches of batchSize rows, 0 for no limit.
+func (c *Cursor) forEach(ctx context.Context, batchSize int, maxBatches int, fn func(r *Rows) error) error {
+	if c.closed {
+		return ErrCursorClosed
+	}
+	if batchSize < 1 {
+		return fmt.Errorf("godal: cursor batch size must be positive, got %d", batchSize)
+	}
+
+	fetchSQL := fmt.Sprintf("FETCH FORWARD %d FROM %s", batchSize, c.name)
+	for batches := 0; !c.done && (maxBatches == 0 || batches < maxBatches); batches++ {
+		fetched := 0
+		err := c.tx.forEach(ctx, fetchSQL, nil, func(r *Rows) error {
+			fetched++
+			return fn(r)
+		})
+		if err != nil {
+			return err
+		}
+		if fetched < batchSize {
+			c.done = true
+		}
+	}
+	return nil
+}
+
+// Close closes the cursor, and commits the transaction it began if any.
+func (c *Cursor) Close() error {
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+
+	_, err := c.tx.exec(context.Background(), "CLOSE "+c.name, nil)
+	if !c.ownTx {
+		return err
+	}
+	if err != nil {
+		c.tx.Rollback()
+		return err
+	}
+
+	if err = c.tx.Commit(); err != nil {
+		log.Error(err)
+	}
+	return err
+}
@@ -0,0 +1,64 @@
+package godal
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNotConnected = errors.New("godal: database is not connected, call Connect first")
+	ErrNotInTx      = errors.New("godal: handle is not inside a transaction")
+	ErrInTx         = errors.New("godal: not allowed on a transaction handle")
+)
+
+// ConnectError is returned by Connect when the pool can not be opened or the
+// database does not answer the ping within the configured retry policy.
+type ConnectError struct {
+	// Op is the step that failed: "open" or "ping".
+	Op       string
+	Host     string
+	Port     string
+	Dbname   string
+	Attempts int
+	Err      error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("godal: %s postgres %s:%s/%s failed after %d attempt(s): %v",
+		e.Op, e.Host, e.Port, e.Dbname, e.Attempts, e.Err)
+}
+
+func (e *ConnectError) Unwrap() error {
+	return e.Err
+}
+
+// ScanError is returned when a column value can not be stored in the struct
+// field it is mapped to.
+type ScanError struct {
+	Column string
+	Field  string
+	Err    error
+}
+
+func (e *ScanError) Error() string {
+	return fmt.Sprintf("godal: scan column %q into field %s: %v", e.Column, e.Field, e.Err)
+}
+
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// CopyError is returned by CopyFrom when a row can not be loaded.
+type CopyError struct {
+	// Row is the 0-based index of the failing row in the input.
+	Row int
+	Err error
+}
+
+func (e *CopyError) Error() string {
+	return fmt.Sprintf("godal: copy row %d: %v", e.Row, e.Err)
+}
+
+func (e *CopyError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,96 @@
+package godal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+var (
+	ErrNotFound = errors.New("godal: no rows in result set")
+)
+
+// Select runs sqlQuery and returns every row scanned into a T, which is a
+// struct or a pointer to a struct mapped with db tags.
+func Select[T any](ctx context.Context, db IDatabase, sqlQuery string, args ...interface{}) ([]T, error) {
+	respStruct, err := structOf[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.ExecuteSelectToStructContext(ctx, sqlQuery, args, respStruct)
+	if err != nil {
+		return nil, err
+	}
+	return collect[T](rows), nil
+}
+
+// errFirstRow stops the iteration of Get after the first row.
+var errFirstRow = errors.New("godal: first row read")
+
+// Get runs sqlQuery and returns its first row scanned into a T, or
+// ErrNotFound when the query returns no row. Outside a transaction the query
+// is stopped after the first row instead of reading the whole result; inside
+// one the rest is read and dropped, so add a LIMIT 1 to large queries.
+func Get[T any](ctx context.Context, db IDatabase, sqlQuery string, args ...interface{}) (T, error) {
+	var result T
+
+	respStruct, err := structOf[T]()
+	if err != nil {
+		return result, err
+	}
+
+	found := false
+	err = db.ForEachStructContext(ctx, sqlQuery, args, respStruct, func(row interface{}) error {
+		result = collect[T]([]interface{}{row})[0]
+		found = true
+		return errFirstRow
+	})
+	if err != nil && err != errFirstRow {
+		return result, err
+	}
+	if !found {
+		return result, ErrNotFound
+	}
+	return result, nil
+}
+
+// GetAll returns the rows of tableName scanned into T, limit -1 returns them all.
+func GetAll[T any](ctx context.Context, db IDatabase, tableName string, limit int, offset int) ([]T, error) {
+	respStruct, err := structOf[T]()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.GetAllToStructContext(ctx, tableName, limit, offset, respStruct)
+	if err != nil {
+		return nil, err
+	}
+	return collect[T](rows.([]interface{})), nil
+}
+
+// structOf returns the zero struct value the struct mappers expect for T.
+func structOf[T any]() (interface{}, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("godal: %s is not a struct type", t)
+	}
+	return reflect.New(t).Elem().Interface(), nil
+}
+
+// collect converts the struct pointers returned by the struct mappers to T.
+func collect[T any](rows []interface{}) []T {
+	result := make([]T, len(rows))
+	for i, row := range rows {
+		if v, ok := row.(T); ok {
+			result[i] = v
+		} else {
+			result[i] = *row.(*T)
+		}
+	}
+	return result
+}
@@ -1,8 +1,10 @@
 package godal
 
+import "context"
+
 type IDatabase interface {
 	// Connect with database
-	Connect()
+	Connect() error
 
 	// Insert a record to table
 	Create(tableName string, mapData map[string]interface{}) (interface{}, error)
@@ -13,17 +15,45 @@ type IDatabase interface {
 	// Create or update a record to table with struct
 	CreateOrUpdate(tableName string, reqStruct interface{}, primaryColumns []string) (interface{}, error)
 
-	// Insert multi record to table
+	// Create or update a record to table with struct, resolving conflicts as described by conflict
+	CreateOrUpdateWithConflict(tableName string, reqStruct interface{}, conflict OnConflict) (interface{}, error)
+
+	// Insert multi record to table, in chunks that fit the parameter limit and run in one transaction
 	CreateBatch(tableName string, listMapData []map[string]interface{}) (interface{}, error)
 
-	// Insert or Update multi record to table
+	// Insert or Update multi record to table, in chunks that fit the parameter limit and run in one transaction
 	CreateOrUpdateBatch(tableName string, listMapData []map[string]interface{}, primaryColumns string) (interface{}, error)
 
-	// Update data on table
-	Update(tableName string, newValue map[string]interface{}, whereCondition map[string]interface{}) (interface{}, error)
+	// Create batch, split into chunks as configured by opts
+	CreateBatchWithOptions(tableName string, listMapData []map[string]interface{}, opts BatchOptions) (interface{}, error)
+
+	// Create or update batch, split into chunks as configured by opts
+	CreateOrUpdateBatchWithOptions(tableName string, listMapData []map[string]interface{}, primaryColumns string, opts BatchOptions) (interface{}, error)
+
+	// Create or update batch, resolving conflicts as described by conflict
+	CreateOrUpdateBatchWithConflict(tableName string, listMapData []map[string]interface{}, conflict OnConflict, opts BatchOptions) (interface{}, error)
+
+	// Return the write methods of the handle with a *WriteResult
+	Writer() IWriter
+
+	// Return a handle whose write methods return the affected rows, see Postgres.Returning
+	Returning(columns ...string) IDatabase
 
-	// Delete record on table
-	Delete(tableName string, whereCondition map[string]interface{}) (interface{}, error)
+	// Update data on table, whereCondition is a map[string]interface{}, a Where or a Condition
+	Update(tableName string, newValue map[string]interface{}, whereCondition interface{}) (interface{}, error)
+
+	// Update the row of a struct, selected by its pk fields. The struct updates
+	// have no older interface{} form, they return a *WriteResult like IWriter
+	UpdateStruct(tableName string, reqStruct interface{}) (*WriteResult, error)
+
+	// Update only fields of the row of a struct, selected by its pk fields
+	UpdateFields(tableName string, reqStruct interface{}, fields ...string) (*WriteResult, error)
+
+	// Update the columns of the row of a struct that changed since snapshot
+	UpdateChanged(tableName string, reqStruct interface{}, snapshot *Snapshot) (*WriteResult, error)
+
+	// Delete record on table, whereCondition is a map[string]interface{}, a Where or a Condition
+	Delete(tableName string, whereCondition interface{}) (interface{}, error)
 
 	// Get all data from table and map to array of struct.
 	GetAllToMap(tableName string, limit int, offset int) ([]map[string]interface{}, error)
@@ -39,4 +69,218 @@ type IDatabase interface {
 
 	// Execute non query
 	Execute(sqlExecute string, params []interface{}) (interface{}, error)
+
+	// Execute query and stream the result row by row
+	ExecuteSelectRows(sqlQuery string, params []interface{}) (*Rows, error)
+
+	// Execute query and call fn with every row as a map, stop at the first error of fn
+	ForEachMap(sqlQuery string, params []interface{}, fn func(row map[string]interface{}) error) error
+
+	// Execute query and call fn with every row as a struct pointer, stop at the first error of fn
+	ForEachStruct(sqlQuery string, params []interface{}, respStruct interface{}, fn func(row interface{}) error) error
+
+	// Start a SELECT query builder on table
+	From(tableName string) *SelectBuilder
+
+	// Start a SELECT query builder on a sub-query
+	FromSubquery(sub *SelectBuilder, alias string) *SelectBuilder
+
+	IDatabaseContext
+}
+
+// IDatabaseContext holds the context-first variants of the IDatabase methods.
+// Cancelling ctx or hitting its deadline cancels the running statement on the server.
+type IDatabaseContext interface {
+	// Connect with database, retrying the ping according to the retry policy
+	ConnectContext(ctx context.Context) error
+
+	// Insert a record to table
+	CreateContext(ctx context.Context, tableName string, mapData map[string]interface{}) (interface{}, error)
+
+	// Insert a record to table with struct
+	CreateWithStructContext(ctx context.Context, tableName string, reqStruct interface{}) (interface{}, error)
+
+	// Create or update a record to table with struct
+	CreateOrUpdateContext(ctx context.Context, tableName string, reqStruct interface{}, primaryColumns []string) (interface{}, error)
+
+	// Create or update a record to table with struct, resolving conflicts as described by conflict
+	CreateOrUpdateWithConflictContext(ctx context.Context, tableName string, reqStruct interface{}, conflict OnConflict) (interface{}, error)
+
+	// Insert multi record to table, in chunks that fit the parameter limit and run in one transaction
+	CreateBatchContext(ctx context.Context, tableName string, listMapData []map[string]interface{}) (interface{}, error)
+
+	// Insert or Update multi record to table, in chunks that fit the parameter limit and run in one transaction
+	CreateOrUpdateBatchContext(ctx context.Context, tableName string, listMapData []map[string]interface{}, primaryColumns string) (interface{}, error)
+
+	// Create batch, split into chunks as configured by opts
+	CreateBatchWithOptionsContext(ctx context.Context, tableName string, listMapData []map[string]interface{}, opts BatchOptions) (interface{}, error)
+
+	// Create or update batch, split into chunks as configured by opts
+	CreateOrUpdateBatchWithOptionsContext(ctx context.Context, tableName string, listMapData []map[string]interface{}, primaryColumns string, opts BatchOptions) (interface{}, error)
+
+	// Create or update batch, resolving conflicts as described by conflict
+	CreateOrUpdateBatchWithConflictContext(ctx context.Context, tableName string, listMapData []map[string]interface{}, conflict OnConflict, opts BatchOptions) (interface{}, error)
+
+	// Update data on table, whereCondition is a map[string]interface{}, a Where or a Condition
+	UpdateContext(ctx context.Context, tableName string, newValue map[string]interface{}, whereCondition interface{}) (interface{}, error)
+
+	// Update the row of a struct, selected by its pk fields
+	UpdateStructContext(ctx context.Context, tableName string, reqStruct interface{}) (*WriteResult, error)
+
+	// Update only fields of the row of a struct, selected by its pk fields
+	UpdateFieldsContext(ctx context.Context, tableName string, reqStruct interface{}, fields ...string) (*WriteResult, error)
+
+	// Update the columns of the row of a struct that changed since snapshot
+	UpdateChangedContext(ctx context.Context, tableName string, reqStruct interface{}, snapshot *Snapshot) (*WriteResult, error)
+
+	// Delete record on table, whereCondition is a map[string]interface{}, a Where or a Condition
+	DeleteContext(ctx context.Context, tableName string, whereCondition interface{}) (interface{}, error)
+
+	// Get all data from table and map to array of map.
+	GetAllToMapContext(ctx context.Context, tableName string, limit int, offset int) ([]map[string]interface{}, error)
+
+	// Get all data from table and map to array of struct.
+	GetAllToStructContext(ctx context.Context, tableName string, limit int, offset int, respStruct interface{}) (interface{}, error)
+
+	// Execute query and return the map
+	ExecuteSelectToMapContext(ctx context.Context, sqlQuery string, params []interface{}) ([]map[string]interface{}, error)
+
+	// Execute query and return the struct
+	ExecuteSelectToStructContext(ctx context.Context, sqlQuery string, params []interface{}, respStruct interface{}) ([]interface{}, error)
+
+	// Execute non query
+	ExecuteContext(ctx context.Context, sqlExecute string, params []interface{}) (interface{}, error)
+
+	// Execute query and stream the result row by row
+	ExecuteSelectRowsContext(ctx context.Context, sqlQuery string, params []interface{}) (*Rows, error)
+
+	// Execute query and call fn with every row as a map, stop at the first error of fn
+	ForEachMapContext(ctx context.Context, sqlQuery string, params []interface{}, fn func(row map[string]interface{}) error) error
+
+	// Execute query and call fn with every row as a struct pointer, stop at the first error of fn
+	ForEachStructContext(ctx context.Context, sqlQuery string, params []interface{}, respStruct interface{}, fn func(row interface{}) error) error
+
+	// Bulk load rows into table with COPY and return the number of rows loaded
+	CopyFrom(ctx context.Context, tableName string, columns []string, rows interface{}) (int64, error)
+
+	// Declare a server-side cursor to read the result of query in batches
+	DeclareCursor(ctx context.Context, sqlQuery string, params []interface{}) (*Cursor, error)
+
+	// Begin a transaction, the returned handle runs every method inside it
+	Begin(ctx context.Context, opts *TxOptions) (ITx, error)
+
+	// Run fn inside a transaction, commit when it returns nil and rollback on error or panic
+	WithTx(ctx context.Context, opts *TxOptions, fn func(tx IDatabase) error) error
+}
+
+// IWriter holds the write methods of IDatabase returning a *WriteResult. The
+// IDatabase methods of the same name return the former interface{} result and
+// are deprecated.
+type IWriter interface {
+	// Insert a record to table
+	Create(tableName string, mapData map[string]interface{}) (*WriteResult, error)
+
+	// Insert a record to table with struct
+	CreateWithStruct(tableName string, reqStruct interface{}) (*WriteResult, error)
+
+	// Create or update a record to table with struct
+	CreateOrUpdate(tableName string, reqStruct interface{}, primaryColumns []string) (*WriteResult, error)
+
+	// Create or update a record to table with struct, resolving conflicts as described by conflict
+	CreateOrUpdateWithConflict(tableName string, reqStruct interface{}, conflict OnConflict) (*WriteResult, error)
+
+	// Insert multi record to table, in chunks that fit the parameter limit and run in one transaction
+	CreateBatch(tableName string, listMapData []map[string]interface{}) (*WriteResult, error)
+
+	// Insert or Update multi record to table, in chunks that fit the parameter limit and run in one transaction
+	CreateOrUpdateBatch(tableName string, listMapData []map[string]interface{}, primaryColumns string) (*WriteResult, error)
+
+	// Create batch, split into chunks as configured by opts
+	CreateBatchWithOptions(tableName string, listMapData []map[string]interface{}, opts BatchOptions) (*WriteResult, error)
+
+	// Create or update batch, split into chunks as configured by opts
+	CreateOrUpdateBatchWithOptions(tableName string, listMapData []map[string]interface{}, primaryColumns string, opts BatchOptions) (*WriteResult, error)
+
+	// Create or update batch, resolving conflicts as described by conflict
+	CreateOrUpdateBatchWithConflict(tableName string, listMapData []map[string]interface{}, conflict OnConflict, opts BatchOptions) (*WriteResult, error)
+
+	// Update data on table, whereCondition is a map[string]interface{}, a Where or a Condition
+	Update(tableName string, newValue map[string]interface{}, whereCondition interface{}) (*WriteResult, error)
+
+	// Update the row of a struct, selected by its pk fields
+	UpdateStruct(tableName string, reqStruct interface{}) (*WriteResult, error)
+
+	// Update only fields of the row of a struct, selected by its pk fields
+	UpdateFields(tableName string, reqStruct interface{}, fields ...string) (*WriteResult, error)
+
+	// Update the columns of the row of a struct that changed since snapshot
+	UpdateChanged(tableName string, reqStruct interface{}, snapshot *Snapshot) (*WriteResult, error)
+
+	// Delete record on table, whereCondition is a map[string]interface{}, a Where or a Condition
+	Delete(tableName string, whereCondition interface{}) (*WriteResult, error)
+
+	// Execute non query
+	Execute(sqlExecute string, params []interface{}) (*WriteResult, error)
+
+	// Insert a record to table
+	CreateContext(ctx context.Context, tableName string, mapData map[string]interface{}) (*WriteResult, error)
+
+	// Insert a record to table with struct
+	CreateWithStructContext(ctx context.Context, tableName string, reqStruct interface{}) (*WriteResult, error)
+
+	// Create or update a record to table with struct
+	CreateOrUpdateContext(ctx context.Context, tableName string, reqStruct interface{}, primaryColumns []string) (*WriteResult, error)
+
+	// Create or update a record to table with struct, resolving conflicts as described by conflict
+	CreateOrUpdateWithConflictContext(ctx context.Context, tableName string, reqStruct interface{}, conflict OnConflict) (*WriteResult, error)
+
+	// Insert multi record to table, in chunks that fit the parameter limit and run in one transaction
+	CreateBatchContext(ctx context.Context, tableName string, listMapData []map[string]interface{}) (*WriteResult, error)
+
+	// Insert or Update multi record to table, in chunks that fit the parameter limit and run in one transaction
+	CreateOrUpdateBatchContext(ctx context.Context, tableName string, listMapData []map[string]interface{}, primaryColumns string) (*WriteResult, error)
+
+	// Create batch, split into chunks as configured by opts
+	CreateBatchWithOptionsContext(ctx context.Context, tableName string, listMapData []map[string]interface{}, opts BatchOptions) (*WriteResult, error)
+
+	// Create or update batch, split into chunks as configured by opts
+	CreateOrUpdateBatchWithOptionsContext(ctx context.Context, tableName string, listMapData []map[string]interface{}, primaryColumns string, opts BatchOptions) (*WriteResult, error)
+
+	// Create or update batch, resolving conflicts as described by conflict
+	CreateOrUpdateBatchWithConflictContext(ctx context.Context, tableName string, listMapData []map[string]interface{}, conflict OnConflict, opts BatchOptions) (*WriteResult, error)
+
+	// Update data on table, whereCondition is a map[string]interface{}, a Where or a Condition
+	UpdateContext(ctx context.Context, tableName string, newValue map[string]interface{}, whereCondition interface{}) (*WriteResult, error)
+
+	// Update the row of a struct, selected by its pk fields
+	UpdateStructContext(ctx context.Context, tableName string, reqStruct interface{}) (*WriteResult, error)
+
+	// Update only fields of the row of a struct, selected by its pk fields
+	UpdateFieldsContext(ctx context.Context, tableName string, reqStruct interface{}, fields ...string) (*WriteResult, error)
+
+	// Update the columns of the row of a struct that changed since snapshot
+	UpdateChangedContext(ctx context.Context, tableName string, reqStruct interface{}, snapshot *Snapshot) (*WriteResult, error)
+
+	// Delete record on table, whereCondition is a map[string]interface{}, a Where or a Condition
+	DeleteContext(ctx context.Context, tableName string, whereCondition interface{}) (*WriteResult, error)
+
+	// Execute non query
+	ExecuteContext(ctx context.Context, sqlExecute string, params []interface{}) (*WriteResult, error)
+}
+
+// ITx is a transaction-scoped IDatabase returned by Begin.
+type ITx interface {
+	IDatabase
+
+	// Commit the transaction
+	Commit() error
+
+	// Commit the transaction, releasing the savepoint of a nested scope with ctx
+	CommitContext(ctx context.Context) error
+
+	// Rollback the transaction
+	Rollback() error
+
+	// Rollback the transaction, rolling back to the savepoint of a nested scope with ctx
+	RollbackContext(ctx context.Context) error
 }
@@ -0,0 +1,218 @@
+package godal
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var (
+	plainIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+	orderKeywords   = map[string]bool{"ASC": true, "DESC": true, "NULLS": true, "FIRST": true, "LAST": true}
+)
+
+// identQuoter quotes the table and column names spliced into generated SQL.
+type identQuoter struct {
+	strict  bool
+	allowed map[string]bool
+}
+
+func (p *Postgres) identQuoter() identQuoter {
+	q := identQuoter{strict: p.StrictIdentifiers}
+	if q.strict {
+		q.allowed = make(map[string]bool, len(p.AllowedIdentifiers))
+		for _, name := range p.AllowedIdentifiers {
+			q.allowed[name] = true
+		}
+	}
+	return q
+}
+
+// quote quotes name with pq.QuoteIdentifier. A schema-qualified name is split
+// on dots and every part quoted on its own, parts already written in double
+// quotes are kept.
+func (q identQuoter) quote(name string) (string, error) {
+	parts, err := splitIdentifier(name)
+	if err != nil {
+		return "", err
+	}
+
+	if q.strict && !q.allowed[name] {
+		for _, part := range parts {
+			if !q.allowed[part] {
+				return "", fmt.Errorf("godal: identifier %q is not allowed", name)
+			}
+		}
+	}
+
+	for i, part := range parts {
+		parts[i] = pq.QuoteIdentifier(part)
+	}
+	return strings.Join(parts, "."), nil
+}
+
+// quoteList quotes every name and joins them with commas.
+func (q identQuoter) quoteList(names []string) (string, error) {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		col, err := q.quote(name)
+		if err != nil {
+			return "", err
+		}
+		quoted[i] = col
+	}
+	return strings.Join(quoted, ", "), nil
+}
+
+// quoteColumn quotes name as a single column identifier, so a mapped struct
+// column such as "shipping.city" stays one column instead of becoming a
+// table-qualified name. A name already written in double quotes is kept.
+func (q identQuoter) quoteColumn(name string) (string, error) {
+	if strings.HasPrefix(name, `"`) {
+		parts, err := splitIdentifier(name)
+		if err != nil {
+			return "", err
+		}
+		if len(parts) != 1 {
+			return "", fmt.Errorf("godal: column %q must not be qualified", name)
+		}
+		name = parts[0]
+	}
+	if name == "" {
+		return "", fmt.Errorf("godal: empty identifier")
+	}
+	if q.strict && !q.allowed[name] {
+		return "", fmt.Errorf("godal: identifier %q is not allowed", name)
+	}
+	return pq.QuoteIdentifier(name), nil
+}
+
+// quoteColumns quotes every name with quoteColumn and joins them with commas.
+func (q identQuoter) quoteColumns(names []string) (string, error) {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		col, err := q.quoteColumn(name)
+		if err != nil {
+			return "", err
+		}
+		quoted[i] = col
+	}
+	return strings.Join(quoted, ", "), nil
+}
+
+// quoteIfIdent quotes expr when it is an identifier, optionally qualified,
+// ending in .* or followed by an alias: "users u", "u.name AS n", "u.*".
+// Any other expression is rejected, the Raw variants of SelectBuilder pass
+// SQL expressions explicitly.
+func (q identQuoter) quoteIfIdent(expr string) (string, error) {
+	fields := strings.Fields(expr)
+	name, alias := "", ""
+	switch {
+	case len(fields) == 1:
+		name = fields[0]
+	case len(fields) == 2:
+		name, alias = fields[0], fields[1]
+	case len(fields) == 3 && strings.EqualFold(fields[1], "AS"):
+		name, alias = fields[0], fields[2]
+	}
+
+	if name == "*" && alias == "" {
+		return name, nil
+	}
+	star := ""
+	if strings.HasSuffix(name, ".*") && alias == "" {
+		name, star = strings.TrimSuffix(name, ".*"), ".*"
+	}
+	if name == "" || !isIdentifier(name) || (alias != "" && !plainIdentifier.MatchString(alias)) {
+		return q.notIdentifier(expr)
+	}
+
+	quoted, err := q.quote(name)
+	if err != nil {
+		return "", err
+	}
+	if alias != "" {
+		// Aliases are named by the statement itself, not the schema, so the
+		// allowlist does not apply to them, here or in FromSubquery.
+		quoted = quoted + " AS " + pq.QuoteIdentifier(alias)
+	}
+	return quoted + star, nil
+}
+
+// quoteOrderBy quotes the column of an ORDER BY item such as "created_at DESC".
+func (q identQuoter) quoteOrderBy(expr string) (string, error) {
+	fields := strings.Fields(expr)
+	if len(fields) == 0 || !isIdentifier(fields[0]) {
+		return q.notIdentifier(expr)
+	}
+	for _, keyword := range fields[1:] {
+		if !orderKeywords[strings.ToUpper(keyword)] {
+			return q.notIdentifier(expr)
+		}
+	}
+
+	col, err := q.quote(fields[0])
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(append([]string{col}, fields[1:]...), " "), nil
+}
+
+// notIdentifier rejects a free-form SQL expression where an identifier is
+// expected, so that a column or sort taken from user input cannot inject SQL.
+func (q identQuoter) notIdentifier(expr string) (string, error) {
+	return "", fmt.Errorf("godal: %q is not an identifier, use SelectRaw, JoinRaw, GroupByRaw or OrderByRaw for SQL expressions", expr)
+}
+
+func isIdentifier(expr string) bool {
+	for _, part := range strings.Split(expr, ".") {
+		if !plainIdentifier.MatchString(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitIdentifier splits a possibly schema-qualified name on the dots that
+// are not inside double quotes, and unescapes the quoted parts.
+func splitIdentifier(name string) ([]string, error) {
+	if name == "" {
+		return nil, fmt.Errorf("godal: empty identifier")
+	}
+
+	var parts []string
+	var part strings.Builder
+	quoted := false
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '"' && !quoted && part.Len() == 0:
+			quoted = true
+		case c == '"' && quoted:
+			if i+1 < len(name) && name[i+1] == '"' {
+				part.WriteByte('"')
+				i++
+			} else {
+				quoted = false
+				if i+1 < len(name) && name[i+1] != '.' {
+					return nil, fmt.Errorf("godal: malformed identifier %q", name)
+				}
+			}
+		case c == '.' && !quoted:
+			if part.Len() == 0 {
+				return nil, fmt.Errorf("godal: malformed identifier %q", name)
+			}
+			parts = append(parts, part.String())
+			part.Reset()
+		default:
+			part.WriteByte(c)
+		}
+	}
+	if quoted || part.Len() == 0 {
+		return nil, fmt.Errorf("godal: malformed identifier %q", name)
+	}
+
+	return append(parts, part.String()), nil
+}
@@ -0,0 +1,350 @@
+package godal
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var (
+	valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// structField is a column mapped to a field of a struct, possibly promoted
+// from an embedded struct or nested in a struct field.
+type structField struct {
+	column string
+	// index is the path from the root struct, as used by FieldByIndex.
+	index []int
+	// name is the Go path of the field, used in error messages.
+	name string
+
+	// The options of the db tag.
+	pk        bool
+	noInsert  bool
+	noUpdate  bool
+	readonly  bool
+	omitEmpty bool
+	json      bool
+}
+
+// insertable reports whether the field is written by INSERT statements.
+func (f structField) insertable() bool {
+	return !f.noInsert && !f.readonly
+}
+
+// updatable reports whether the field is written by UPDATE statements and
+// the DO UPDATE of upserts.
+func (f structField) updatable() bool {
+	return !f.noUpdate && !f.readonly && !f.pk
+}
+
+// parseDBTag splits a db tag such as "id,pk,no_insert" or ",prefix=addr_"
+// into the column name and its options.
+func parseDBTag(tag string) (string, map[string]string) {
+	parts := strings.Split(tag, ",")
+	opts := make(map[string]string, len(parts)-1)
+	for _, opt := range parts[1:] {
+		opt = strings.TrimSpace(opt)
+		if opt == "" {
+			continue
+		}
+		if i := strings.Index(opt, "="); i >= 0 {
+			opts[opt[:i]] = opt[i+1:]
+		} else {
+			opts[opt] = ""
+		}
+	}
+	return strings.TrimSpace(parts[0]), opts
+}
+
+// structFields returns the columns mapped by the db tags of t. The tag is the
+// column name followed by options, or "-" for a field that is not mapped:
+//
+//	pk         part of the primary key, the default conflict target of upserts
+//	no_insert  not written by INSERT, e.g. a serial id
+//	no_update  not written by UPDATE or the DO UPDATE of upserts
+//	readonly   never written, only scanned
+//	omitempty  not written when it holds the zero value
+//	json       always stored as JSON, and decoded from JSON when scanned
+//	prefix=p   nested struct whose columns are named p<column>
+//
+// Anonymous embedded structs are flattened into their parent. A struct field
+// whose type has db tags of its own is nested: its columns are named
+// "field.column", or "<prefix>column" with the prefix=<prefix> option, so
+// `db:",prefix=addr_"` maps addr_city and `db:"address"` maps "address.city".
+// Other struct, map and slice fields are single (JSON) columns, and so is a
+// field whose type is already being mapped, such as Parent *Node in Node.
+//
+// The result is cached per type and must not be modified.
+func structFields(t reflect.Type) []structField {
+	return planOf(t).fields
+}
+
+// structPlan is the mapping of a struct type, computed once per type.
+type structPlan struct {
+	fields   []structField
+	byColumn map[string]*structField
+}
+
+// structPlans caches the *structPlan of every mapped reflect.Type.
+var structPlans sync.Map
+
+func planOf(t reflect.Type) *structPlan {
+	if plan, ok := structPlans.Load(t); ok {
+		return plan.(*structPlan)
+	}
+
+	fields := appendStructFields(nil, t, nil, "", "", nil)
+	plan := &structPlan{fields: fields, byColumn: make(map[string]*structField, len(fields))}
+	for i := range plan.fields {
+		plan.byColumn[plan.fields[i].column] = &plan.fields[i]
+	}
+
+	actual, _ := structPlans.LoadOrStore(t, plan)
+	return actual.(*structPlan)
+}
+
+// columnFields returns the field each column of a result set is scanned
+// into, nil for the columns the struct does not map.
+func (p *structPlan) columnFields(colNames []string) []*structField {
+	fields := make([]*structField, len(colNames))
+	for i, col := range colNames {
+		fields[i] = p.byColumn[col]
+	}
+	return fields
+}
+
+// scanStructRow stores the driver values of one row into the struct dst.
+func scanStructRow(dst reflect.Value, fields []*structField, colNames []string, cols []interface{}) error {
+	for i, col := range cols {
+		field := fields[i]
+		if field == nil {
+			continue
+		}
+
+		// NULL columns leave the nil pointers of nested structs alone.
+		var fieldVal reflect.Value
+		if col == nil {
+			fieldVal = fieldByIndex(dst, field.index)
+		} else {
+			fieldVal = fieldByIndexAlloc(dst, field.index)
+		}
+		if !fieldVal.IsValid() || !fieldVal.CanSet() {
+			continue
+		}
+
+		var err error
+		if field.json {
+			err = convertJSON(fieldVal, col)
+		} else {
+			err = convertAssign(fieldVal, col)
+		}
+		if err != nil {
+			return &ScanError{Column: colNames[i], Field: dst.Type().Name() + "." + field.name, Err: err}
+		}
+	}
+	return nil
+}
+
+// appendStructFields appends the columns of t. parents holds the struct types
+// being mapped above t: a field of one of those types would nest forever, so
+// it is stored as a JSON column instead, and skipped when embedded.
+func appendStructFields(fields []structField, t reflect.Type, index []int, prefix string, path string, parents []reflect.Type) []structField {
+	parents = append(parents[:len(parents):len(parents)], t)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldIndex := append(append([]int(nil), index...), i)
+		fieldPath := path + field.Name
+
+		tag, tagged := field.Tag.Lookup("db")
+		name, opts := parseDBTag(tag)
+		if name == "-" {
+			continue
+		}
+		_, forceJSON := opts["json"]
+		structType := indirectType(field.Type)
+		recursive := containsType(parents, structType)
+
+		if field.Anonymous && name == "" && structType.Kind() == reflect.Struct {
+			if recursive || (!field.IsExported() && field.Type.Kind() == reflect.Ptr) {
+				continue
+			}
+			fields = appendStructFields(fields, structType, fieldIndex, prefix+opts["prefix"], fieldPath+".", parents)
+			continue
+		}
+
+		if !field.IsExported() || !tagged {
+			continue
+		}
+
+		if recursive {
+			forceJSON = true
+		} else if nestedPrefix, ok := opts["prefix"]; ok || (name != "" && !forceJSON && isNestedStruct(field.Type)) {
+			if !ok {
+				nestedPrefix = name + "."
+			}
+			fields = appendStructFields(fields, structType, fieldIndex, prefix+nestedPrefix, fieldPath+".", parents)
+			continue
+		}
+
+		if name == "" {
+			continue
+		}
+
+		_, pk := opts["pk"]
+		_, noInsert := opts["no_insert"]
+		_, noUpdate := opts["no_update"]
+		_, readonly := opts["readonly"]
+		_, omitEmpty := opts["omitempty"]
+		fields = append(fields, structField{
+			column:    prefix + name,
+			index:     fieldIndex,
+			name:      fieldPath,
+			pk:        pk,
+			noInsert:  noInsert,
+			noUpdate:  noUpdate,
+			readonly:  readonly,
+			omitEmpty: omitEmpty,
+			json:      forceJSON,
+		})
+	}
+	return fields
+}
+
+func containsType(types []reflect.Type, t reflect.Type) bool {
+	for _, x := range types {
+		if x == t {
+			return true
+		}
+	}
+	return false
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		return t.Elem()
+	}
+	return t
+}
+
+// isNestedStruct reports whether t is a struct mapped column by column rather
+// than a value stored in one column.
+func isNestedStruct(t reflect.Type) bool {
+	st := indirectType(t)
+	if st.Kind() != reflect.Struct || st == timeType {
+		return false
+	}
+	if t.Implements(valuerType) || reflect.PtrTo(st).Implements(scannerType) {
+		return false
+	}
+
+	for i := 0; i < st.NumField(); i++ {
+		if _, ok := st.Field(i).Tag.Lookup("db"); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldByIndex returns the field at index, or an invalid Value when the path
+// goes through a nil pointer.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// fieldByIndexAlloc returns the field at index, allocating the nil pointers
+// on the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// fieldParam returns the parameter written for a field, and false when an
+// omitempty field holds its zero value.
+func fieldParam(root reflect.Value, field structField) (interface{}, bool) {
+	v := fieldByIndex(root, field.index)
+	if field.omitEmpty && (!v.IsValid() || v.IsZero()) {
+		return nil, false
+	}
+	if field.json {
+		if !v.IsValid() || isNil(v) {
+			return nil, true
+		}
+		b, _ := json.Marshal(v.Interface())
+		return b, true
+	}
+	return paramValue(v), true
+}
+
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// columnsOf returns the column names of fields.
+func columnsOf(fields []structField) []string {
+	columns := make([]string, len(fields))
+	for i, field := range fields {
+		columns[i] = field.column
+	}
+	return columns
+}
+
+// paramValue converts a field value to a statement parameter: nil pointers
+// become NULL and composite values are JSON encoded.
+func paramValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if v.Type().Implements(valuerType) {
+		return v.Interface()
+	}
+
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Type() == timeType || v.Type().Implements(valuerType) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		b, _ := json.Marshal(v.Interface())
+		return b
+	case reflect.Map, reflect.Array, reflect.Struct:
+		b, _ := json.Marshal(v.Interface())
+		return b
+	}
+
+	return v.Interface()
+}
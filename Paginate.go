@@ -0,0 +1,328 @@
+package godal
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrInvalidPageCursor = errors.New("godal: invalid page cursor")
+)
+
+// PageRequest asks for one page of a keyset (seek) pagination.
+type PageRequest struct {
+	// OrderBy lists the sort columns with an optional ASC or DESC, e.g.
+	// []string{"created_at DESC", "id"}. The columns must be selected, NOT
+	// NULL, and together unique so that every row has its own position.
+	OrderBy []string
+	// Size is the number of rows of the page.
+	Size int
+	// Cursor is the Next or Prev token of a previous page, empty for the first page.
+	Cursor string
+	// WithTotal also counts all the rows of the query, ignoring the cursor.
+	WithTotal bool
+}
+
+// Page is one page of a keyset pagination. Next and Prev are opaque tokens to
+// pass as PageRequest.Cursor, empty when there is no such page.
+type Page struct {
+	// Items is a []map[string]interface{} for Paginate and a []interface{}
+	// of struct pointers for PaginateStruct.
+	Items interface{} `json:"items"`
+	Next  string      `json:"next,omitempty"`
+	Prev  string      `json:"prev,omitempty"`
+	Total *int64      `json:"total,omitempty"`
+}
+
+type sortKey struct {
+	column string
+	// label is the name of the column in the result, the last part of column.
+	label string
+	desc  bool
+}
+
+// pageToken is the content of the Next and Prev cursors.
+type pageToken struct {
+	Backward bool          `json:"b,omitempty"`
+	OrderBy  []string      `json:"o"`
+	Values   []interface{} `json:"v"`
+}
+
+// Paginate runs the query one page at a time, ordered by req.OrderBy, and
+// returns the rows as maps.
+func (b *SelectBuilder) Paginate(req PageRequest) (*Page, error) {
+	return b.PaginateContext(context.Background(), req)
+}
+
+func (b *SelectBuilder) PaginateContext(ctx context.Context, req PageRequest) (*Page, error) {
+	page, items, err := b.paginate(ctx, req, func(q *SelectBuilder) ([]interface{}, error) {
+		rows, err := q.ToMapContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		items := make([]interface{}, len(rows))
+		for i, row := range rows {
+			items[i] = row
+		}
+		return items, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		rows[i] = item.(map[string]interface{})
+	}
+	page.Items = rows
+	return page, nil
+}
+
+// PaginateStruct is Paginate returning the rows as pointers to new copies of respStruct.
+func (b *SelectBuilder) PaginateStruct(req PageRequest, respStruct interface{}) (*Page, error) {
+	return b.PaginateStructContext(context.Background(), req, respStruct)
+}
+
+func (b *SelectBuilder) PaginateStructContext(ctx context.Context, req PageRequest, respStruct interface{}) (*Page, error) {
+	page, items, err := b.paginate(ctx, req, func(q *SelectBuilder) ([]interface{}, error) {
+		return q.ToStructContext(ctx, respStruct)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page.Items = items
+	return page, nil
+}
+
+func (b *SelectBuilder) paginate(ctx context.Context, req PageRequest, fetch func(q *SelectBuilder) ([]interface{}, error)) (*Page, []interface{}, error) {
+	if req.Size < 1 {
+		return nil, nil, fmt.Errorf("godal: page size must be positive, got %d", req.Size)
+	}
+	keys, err := parseSortKeys(req.OrderBy)
+	if err != nil {
+		log.Error(err)
+		return nil, nil, err
+	}
+
+	var token pageToken
+	if req.Cursor != "" {
+		if token, err = decodePageToken(req.Cursor, req.OrderBy); err != nil {
+			log.Error(err)
+			return nil, nil, err
+		}
+	}
+
+	// Walking backward reverses the sort, the rows are put back in order below.
+	q := b.clone()
+	q.orderBy = nil
+	for _, key := range keys {
+		desc := key.desc != token.Backward
+		if desc {
+			q.OrderBy(key.column + " DESC")
+		} else {
+			q.OrderBy(key.column + " ASC")
+		}
+	}
+	if req.Cursor != "" {
+		q.where = append(q.where, keysetCondition(keys, token.Values, token.Backward))
+	}
+	q.limit = req.Size + 1
+	q.offset = 0
+
+	items, err := fetch(q)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hasMore := len(items) > req.Size
+	if hasMore {
+		items = items[:req.Size]
+	}
+	if token.Backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+		}
+	}
+
+	page := &Page{}
+	hasNext, hasPrev := hasMore, req.Cursor != ""
+	if token.Backward {
+		hasNext, hasPrev = true, hasMore
+	}
+	if len(items) > 0 {
+		if hasNext {
+			if page.Next, err = encodePageToken(req.OrderBy, keys, items[len(items)-1], false); err != nil {
+				return nil, nil, err
+			}
+		}
+		if hasPrev {
+			if page.Prev, err = encodePageToken(req.OrderBy, keys, items[0], true); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	if req.WithTotal {
+		total, err := b.count(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		page.Total = &total
+	}
+
+	return page, items, nil
+}
+
+// count returns the number of rows of the query without its order and limits.
+func (b *SelectBuilder) count(ctx context.Context) (int64, error) {
+	sub := b.clone()
+	sub.orderBy = nil
+	sub.limit = -1
+	sub.offset = 0
+
+	rows, err := b.db.FromSubquery(sub, "godal_count").SelectRaw("count(*) AS total").ToMapContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	total, _ := rows[0]["total"].(int64)
+	return total, nil
+}
+
+func (b *SelectBuilder) clone() *SelectBuilder {
+	c := *b
+	c.columns = append([]sqlExpr(nil), b.columns...)
+	c.joins = append([]joinClause(nil), b.joins...)
+	c.where = append([]Condition(nil), b.where...)
+	c.groupBy = append([]sqlExpr(nil), b.groupBy...)
+	c.having = append([]Condition(nil), b.having...)
+	c.orderBy = append([]sqlExpr(nil), b.orderBy...)
+	return &c
+}
+
+func parseSortKeys(orderBy []string) ([]sortKey, error) {
+	if len(orderBy) == 0 {
+		return nil, errors.New("godal: keyset pagination needs at least one sort column")
+	}
+
+	keys := make([]sortKey, len(orderBy))
+	for i, expr := range orderBy {
+		fields := strings.Fields(expr)
+		if len(fields) == 0 || len(fields) > 2 || !isIdentifier(fields[0]) {
+			return nil, fmt.Errorf("godal: unsupported sort column %q", expr)
+		}
+
+		keys[i].column = fields[0]
+		parts := strings.Split(fields[0], ".")
+		keys[i].label = parts[len(parts)-1]
+
+		if len(fields) == 2 {
+			switch strings.ToUpper(fields[1]) {
+			case "ASC":
+			case "DESC":
+				keys[i].desc = true
+			default:
+				return nil, fmt.Errorf("godal: unsupported sort column %q", expr)
+			}
+		}
+	}
+	return keys, nil
+}
+
+// keysetCondition selects the rows after values in the sort order, or before
+// them when backward is set:
+//
+//	(k1 > v1) OR (k1 = v1 AND k2 > v2) OR ...
+func keysetCondition(keys []sortKey, values []interface{}, backward bool) Condition {
+	ors := make([]Condition, len(keys))
+	for i, key := range keys {
+		ands := make([]Condition, 0, i+1)
+		for j := 0; j < i; j++ {
+			ands = append(ands, Eq(keys[j].column, values[j]))
+		}
+		if key.desc != backward {
+			ands = append(ands, Lt(key.column, values[i]))
+		} else {
+			ands = append(ands, Gt(key.column, values[i]))
+		}
+		if len(ands) == 1 {
+			ors[i] = ands[0]
+		} else {
+			ors[i] = And(ands...)
+		}
+	}
+	return Or(ors...)
+}
+
+func encodePageToken(orderBy []string, keys []sortKey, item interface{}, backward bool) (string, error) {
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		value, err := sortValue(item, key.label)
+		if err != nil {
+			return "", err
+		}
+		values[i] = value
+	}
+
+	b, err := json.Marshal(pageToken{Backward: backward, OrderBy: orderBy, Values: values})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodePageToken(cursor string, orderBy []string) (pageToken, error) {
+	var token pageToken
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return token, ErrInvalidPageCursor
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(b)))
+	decoder.UseNumber()
+	if err = decoder.Decode(&token); err != nil {
+		return token, ErrInvalidPageCursor
+	}
+	if !reflect.DeepEqual(token.OrderBy, orderBy) || len(token.Values) != len(orderBy) {
+		return token, fmt.Errorf("%w: it was issued for another sort order", ErrInvalidPageCursor)
+	}
+	return token, nil
+}
+
+// sortValue returns the value of column in a map row or a struct pointer row.
+func sortValue(item interface{}, column string) (interface{}, error) {
+	var value interface{}
+	found := false
+
+	if row, ok := item.(map[string]interface{}); ok {
+		value, found = row[column]
+	} else {
+		v := reflect.ValueOf(item).Elem()
+		if field, ok := planOf(v.Type()).byColumn[column]; ok {
+			fieldVal := fieldByIndex(v, field.index)
+			if fieldVal.IsValid() && !isNil(fieldVal) {
+				value, found = reflect.Indirect(fieldVal).Interface(), true
+			}
+		}
+	}
+	if !found || value == nil {
+		return nil, fmt.Errorf("godal: sort column %q must be selected and not NULL", column)
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return string(v), nil
+	case time.Time:
+		return v.Format(time.RFC3339Nano), nil
+	}
+	return value, nil
+}
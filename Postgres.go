@@ -1,9 +1,11 @@
 package godal
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 
 	"database/sql"
@@ -12,480 +14,532 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
-var (
-	DBConn *sql.DB
-)
+func (p *Postgres) Connect() error {
+	return p.ConnectContext(context.Background())
+}
+
+func (p *Postgres) ConnectContext(ctx context.Context) error {
+	if p.tx != nil {
+		return ErrInTx
+	}
 
-func (p Postgres) Connect() {
 	strConn := fmt.Sprintf("host=%s port=%s user=%s dbname=%s password=%s sslmode=%s",
 		p.Host, p.Port, p.User, p.Dbname, p.Pass, p.SSLMode)
 	db, err := sql.Open("postgres", strConn)
-	db.SetMaxIdleConns(int(p.MaxIdleConn))
-	db.SetMaxOpenConns(int(p.MaxOpenConn))
 	if err != nil {
-		panic(err)
+		log.Error(err)
+		return p.connectError("open", 1, err)
 	}
+	db.SetMaxIdleConns(int(p.MaxIdleConn))
+	db.SetMaxOpenConns(int(p.MaxOpenConn))
 
-	err = db.Ping()
-	if err != nil {
-		panic(err)
+	attempt := 1
+	for {
+		err = db.PingContext(ctx)
+		if err == nil {
+			break
+		}
+
+		if attempt >= p.ConnectRetry.attempts() || ctx.Err() != nil {
+			log.Error(err)
+			db.Close()
+			return p.connectError("ping", attempt, err)
+		}
+
+		log.Warnf("Ping postgres database %s:%s/%s failed (attempt %d): %v", p.Host, p.Port, p.Dbname, attempt, err)
+		if waitErr := p.ConnectRetry.wait(ctx, attempt); waitErr != nil {
+			db.Close()
+			return p.connectError("ping", attempt, err)
+		}
+		attempt++
 	}
 
 	log.Printf("Connect to postgres database %s:%s/%s successful", p.Host, p.Port, p.Dbname)
-	DBConn = db
+	if p.db != nil {
+		p.db.Close()
+	}
+	p.db = db
+	return nil
+}
+
+func (p *Postgres) connectError(op string, attempts int, err error) error {
+	return &ConnectError{
+		Op:       op,
+		Host:     p.Host,
+		Port:     p.Port,
+		Dbname:   p.Dbname,
+		Attempts: attempts,
+		Err:      err,
+	}
+}
+
+// DB returns the connection pool owned by this instance, or nil before Connect.
+func (p *Postgres) DB() *sql.DB {
+	return p.db
+}
+
+// Close closes the connection pool owned by this instance.
+func (p *Postgres) Close() error {
+	if p.tx != nil {
+		return ErrInTx
+	}
+	if p.db == nil {
+		return nil
+	}
+	err := p.db.Close()
+	p.db = nil
+	return err
+}
+
+// executor is the part of *sql.DB and *sql.Tx used to run statements.
+type executor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// conn returns the transaction of a transaction-scoped handle, or the pool otherwise.
+func (p *Postgres) conn() (executor, error) {
+	if p.tx != nil {
+		return p.tx.tx, nil
+	}
+	if p.db == nil {
+		log.Error(ErrNotConnected)
+		return nil, ErrNotConnected
+	}
+	return p.db, nil
+}
+
+func (w writer) Create(tableName string, mapData map[string]interface{}) (*WriteResult, error) {
+	return w.CreateContext(context.Background(), tableName, mapData)
 }
 
-func (p Postgres) Create(tableName string, mapData map[string]interface{}) (interface{}, error) {
+func (w writer) CreateContext(ctx context.Context, tableName string, mapData map[string]interface{}) (*WriteResult, error) {
+	p := w.db
 	sqlStatement := `
 		INSERT INTO %s(%s) 
-		VALUES (%s) 
-		RETURNING *
+		VALUES (%s)
 	`
-	arrValues, strParams, strValues := convertMapToParams(mapData)
-	sqlStatement = fmt.Sprintf(sqlStatement, tableName, strParams, strValues)
+	idents := p.identQuoter()
+	arrValues, listColumns, strValues := convertMapToParams(mapData)
+	strTable, strParams, err := quoteTableAndColumns(idents, tableName, listColumns)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	sqlStatement = fmt.Sprintf(sqlStatement, strTable, strParams, strValues)
 
-	rs, err := DBConn.Exec(sqlStatement, arrValues...)
+	return p.write(ctx, sqlStatement, arrValues, nil)
+}
+
+func (w writer) CreateWithStruct(tableName string, reqStruct interface{}) (*WriteResult, error) {
+	return w.CreateWithStructContext(context.Background(), tableName, reqStruct)
+}
+
+func (w writer) CreateWithStructContext(ctx context.Context, tableName string, reqStruct interface{}) (*WriteResult, error) {
+	p := w.db
+	sqlStatement, arrValues, err := insertStructSQL(p.identQuoter(), tableName, reqStruct)
 	if err != nil {
 		log.Error(err)
 		return nil, err
 	}
 
-	return rs, nil
+	return p.write(ctx, sqlStatement, arrValues, reqStruct)
 }
 
-func (p Postgres) CreateWithStruct(tableName string, reqStruct interface{}) (interface{}, error) {
+// insertStructSQL builds the INSERT of the insertable fields of reqStruct.
+func insertStructSQL(idents identQuoter, tableName string, reqStruct interface{}) (string, []interface{}, error) {
 	sqlStatement := `
 		INSERT INTO %s(%s) 
-		VALUES (%s) 
-		RETURNING *
+		VALUES (%s)
 	`
-	arrValues, strParams, strValues := convertStructToParams(reqStruct)
-	sqlStatement = fmt.Sprintf(sqlStatement, tableName, strParams, strValues)
-
-	rs, err := DBConn.Exec(sqlStatement, arrValues...)
+	arrValues, listFields, strValues := convertStructToParams(reqStruct)
+	strTable, strParams, err := quoteTableAndColumns(idents, tableName, columnsOf(listFields))
 	if err != nil {
-		log.Error(err)
-		return nil, err
+		return "", nil, err
 	}
+	return fmt.Sprintf(sqlStatement, strTable, strParams, strValues), arrValues, nil
+}
 
-	return rs, nil
+func (w writer) CreateOrUpdate(tableName string, reqStruct interface{}, primaryColumns []string) (*WriteResult, error) {
+	return w.CreateOrUpdateContext(context.Background(), tableName, reqStruct, primaryColumns)
+}
+
+// CreateOrUpdateContext upserts reqStruct. When primaryColumns is empty the
+// fields tagged pk are the conflict target.
+func (w writer) CreateOrUpdateContext(ctx context.Context, tableName string, reqStruct interface{}, primaryColumns []string) (*WriteResult, error) {
+	return w.CreateOrUpdateWithConflictContext(ctx, tableName, reqStruct, OnConflict{Columns: primaryColumns})
+}
+
+func (w writer) CreateOrUpdateWithConflict(tableName string, reqStruct interface{}, conflict OnConflict) (*WriteResult, error) {
+	return w.CreateOrUpdateWithConflictContext(context.Background(), tableName, reqStruct, conflict)
 }
 
-func (p Postgres) CreateOrUpdate(tableName string, reqStruct interface{}, primaryColumns []string) (interface{}, error) {
+func (w writer) CreateOrUpdateWithConflictContext(ctx context.Context, tableName string, reqStruct interface{}, conflict OnConflict) (*WriteResult, error) {
+	p := w.db
 	sqlStatement := `
 		INSERT INTO %s(%s)
 		VALUES (%s)
-		ON CONFLICT (%s) DO UPDATE
-		  SET %s
+		%s
 		`
-	arrValues, strParams, strValues := convertStructToParams(reqStruct)
-	strPrimary := strings.Join(primaryColumns, ",")
-
-	mapPrimary := make(map[string]bool)
-	for _, primary := range primaryColumns {
-		mapPrimary[primary] = true
+	idents := p.identQuoter()
+	arrValues, listFields, strValues := convertStructToParams(reqStruct)
+	strTable, strParams, err := quoteTableAndColumns(idents, tableName, columnsOf(listFields))
+	if err != nil {
+		log.Error(err)
+		return nil, err
 	}
 
-	excludeStm := ""
-	arrParams := strings.Split(strParams, ",")
-	for _, param := range arrParams {
-		col := strings.Trim(param, " ")
-		if !mapPrimary[col] {
-			if excludeStm == "" {
-				excludeStm = fmt.Sprintf("%s = EXCLUDED.%s", col, col)
-			} else {
-				excludeStm = excludeStm + ", " + fmt.Sprintf("%s = EXCLUDED.%s", col, col)
+	if len(conflict.Columns) == 0 && conflict.Constraint == "" {
+		for _, field := range structFields(reflect.Indirect(reflect.ValueOf(reqStruct)).Type()) {
+			if field.pk {
+				conflict.Columns = append(conflict.Columns, field.column)
 			}
 		}
 	}
+	if len(conflict.Columns) == 0 && conflict.Constraint == "" {
+		err = fmt.Errorf("godal: no conflict columns for %s, pass primaryColumns or tag fields with pk", tableName)
+		log.Error(err)
+		return nil, err
+	}
 
-	sqlStatement = fmt.Sprintf(sqlStatement, tableName, strParams, strValues, strPrimary, excludeStm)
-
-	rs, err := DBConn.Exec(sqlStatement, arrValues...)
+	updatable := make(map[string]bool)
+	for _, field := range listFields {
+		updatable[field.column] = field.updatable()
+	}
+	strConflict, err := conflict.toSQL(idents, strTable, columnsOf(listFields), func(col string) bool {
+		return updatable[col]
+	})
 	if err != nil {
 		log.Error(err)
 		return nil, err
 	}
 
-	return rs, nil
+	sqlStatement = fmt.Sprintf(sqlStatement, strTable, strParams, strValues, strConflict)
+
+	return p.write(ctx, sqlStatement, arrValues, reqStruct)
+}
+
+func (w writer) CreateBatch(tableName string, listMapData []map[string]interface{}) (*WriteResult, error) {
+	return w.CreateBatchWithOptionsContext(context.Background(), tableName, listMapData, BatchOptions{})
 }
 
-func (p Postgres) CreateBatch(tableName string, listMapData []map[string]interface{}) (interface{}, error) {
+func (w writer) CreateBatchContext(ctx context.Context, tableName string, listMapData []map[string]interface{}) (*WriteResult, error) {
+	return w.CreateBatchWithOptionsContext(ctx, tableName, listMapData, BatchOptions{})
+}
+
+func (w writer) CreateBatchWithOptions(tableName string, listMapData []map[string]interface{}, opts BatchOptions) (*WriteResult, error) {
+	return w.CreateBatchWithOptionsContext(context.Background(), tableName, listMapData, opts)
+}
+
+func (w writer) CreateBatchWithOptionsContext(ctx context.Context, tableName string, listMapData []map[string]interface{}, opts BatchOptions) (*WriteResult, error) {
+	p := w.db
 	sqlStatement := `
 		INSERT INTO %s(%s) 
 		VALUES %s
 		`
 
-	listColumns, listColumnsText := getListColumns(listMapData)
-	arrValues, values := convertListMapToParams(listMapData, listColumns)
-
-	sqlStatement = fmt.Sprintf(sqlStatement, tableName, listColumnsText, values)
-
-	rs, err := DBConn.Exec(sqlStatement, arrValues...)
+	idents := p.identQuoter()
+	listColumns, err := batchColumns(listMapData, opts)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	strTable, listColumnsText, err := quoteTableAndColumns(idents, tableName, listColumns)
 	if err != nil {
 		log.Error(err)
 		return nil, err
 	}
 
-	return rs, nil
+	return p.execChunks(ctx, listMapData, len(listColumns), opts, func(chunk []map[string]interface{}) (string, []interface{}) {
+		arrValues, values := convertListMapToParams(chunk, listColumns, opts.MissingAsDefault)
+		return fmt.Sprintf(sqlStatement, strTable, listColumnsText, values), arrValues
+	})
+}
+
+func (w writer) CreateOrUpdateBatch(tableName string, listMapData []map[string]interface{}, primaryBatch string) (*WriteResult, error) {
+	return w.CreateOrUpdateBatchWithOptionsContext(context.Background(), tableName, listMapData, primaryBatch, BatchOptions{})
+}
+
+func (w writer) CreateOrUpdateBatchContext(ctx context.Context, tableName string, listMapData []map[string]interface{}, primaryBatch string) (*WriteResult, error) {
+	return w.CreateOrUpdateBatchWithOptionsContext(ctx, tableName, listMapData, primaryBatch, BatchOptions{})
 }
 
-func (p Postgres) CreateOrUpdateBatch(tableName string, listMapData []map[string]interface{}, primaryBatch string) (interface{}, error) {
+func (w writer) CreateOrUpdateBatchWithOptions(tableName string, listMapData []map[string]interface{}, primaryBatch string, opts BatchOptions) (*WriteResult, error) {
+	return w.CreateOrUpdateBatchWithOptionsContext(context.Background(), tableName, listMapData, primaryBatch, opts)
+}
+
+func (w writer) CreateOrUpdateBatchWithOptionsContext(ctx context.Context, tableName string, listMapData []map[string]interface{}, primaryBatch string, opts BatchOptions) (*WriteResult, error) {
+	return w.CreateOrUpdateBatchWithConflictContext(ctx, tableName, listMapData, conflictColumns(primaryBatch), opts)
+}
+
+func (w writer) CreateOrUpdateBatchWithConflict(tableName string, listMapData []map[string]interface{}, conflict OnConflict, opts BatchOptions) (*WriteResult, error) {
+	return w.CreateOrUpdateBatchWithConflictContext(context.Background(), tableName, listMapData, conflict, opts)
+}
+
+func (w writer) CreateOrUpdateBatchWithConflictContext(ctx context.Context, tableName string, listMapData []map[string]interface{}, conflict OnConflict, opts BatchOptions) (*WriteResult, error) {
+	p := w.db
 	sqlStatement := `
 		INSERT INTO %s(%s)
 		VALUES %s
-		ON CONFLICT (%s) DO UPDATE
-		  SET %s
+		%s
 		`
 
-	excludeStm := ""
-
-	listColumns, listColumnsText := getListColumns(listMapData)
-	arrValues, values := convertListMapToParams(listMapData, listColumns)
-
-	//Get Exclude Statement
-	for i := 0; i < len(listColumns); i++ {
-		col := listColumns[i]
-		if col != primaryBatch {
-			if excludeStm == "" {
-				excludeStm = fmt.Sprintf("%s = EXCLUDED.%s", col, col)
-			} else {
-				excludeStm = excludeStm + ", " + fmt.Sprintf("%s = EXCLUDED.%s", col, col)
-			}
-		}
+	idents := p.identQuoter()
+	listColumns, err := batchColumns(listMapData, opts)
+	if err != nil {
+		log.Error(err)
+		return nil, err
 	}
-
-	sqlStatement = fmt.Sprintf(sqlStatement, tableName, listColumnsText, values, primaryBatch, excludeStm)
-
-	rs, err := DBConn.Exec(sqlStatement, arrValues...)
+	strTable, listColumnsText, err := quoteTableAndColumns(idents, tableName, listColumns)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	strConflict, err := conflict.toSQL(idents, strTable, listColumns, nil)
 	if err != nil {
 		log.Error(err)
 		return nil, err
 	}
 
-	return rs, nil
+	return p.execChunks(ctx, listMapData, len(listColumns), opts, func(chunk []map[string]interface{}) (string, []interface{}) {
+		arrValues, values := convertListMapToParams(chunk, listColumns, opts.MissingAsDefault)
+		return fmt.Sprintf(sqlStatement, strTable, listColumnsText, values, strConflict), arrValues
+	})
+}
+
+func (w writer) Update(tableName string, newValue map[string]interface{}, whereCondition interface{}) (*WriteResult, error) {
+	return w.UpdateContext(context.Background(), tableName, newValue, whereCondition)
 }
 
-func (p Postgres) Update(tableName string, newValue map[string]interface{}, whereCondition map[string]interface{}) (interface{}, error) {
+func (w writer) UpdateContext(ctx context.Context, tableName string, newValue map[string]interface{}, whereCondition interface{}) (*WriteResult, error) {
+	p := w.db
+	return p.update(ctx, tableName, newValue, whereCondition, nil)
+}
+
+// update runs the UPDATE of newValue, writing the row returned on a Returning handle into dest.
+func (p *Postgres) update(ctx context.Context, tableName string, newValue map[string]interface{}, whereCondition interface{}, dest interface{}) (*WriteResult, error) {
 	sqlStatement := `
 		UPDATE %s 
 		SET %s 
 		WHERE %s
 	`
 
-	arrSet, strSet, loopIndex := buildConditionQuery(newValue, ",", 1)
-	arrWhere, strWhere, _ := buildConditionQuery(whereCondition, " AND", loopIndex)
-	sqlStatement = fmt.Sprintf(sqlStatement, tableName, strSet, strWhere)
-	arrValues := make([]interface{}, 0)
-	arrValues = append(arrSet, arrWhere...)
-
-	rs, err := DBConn.Exec(sqlStatement, arrValues...)
+	args := &queryArgs{idents: p.identQuoter()}
+	strTable, err := args.idents.quote(tableName)
 	if err != nil {
 		log.Error(err)
 		return nil, err
 	}
-
-	return rs, nil
-}
-
-func (p Postgres) Delete(tableName string, whereCondition map[string]interface{}) (interface{}, error) {
-	sqlStatement := `DELETE FROM %s WHERE %s`
-	arrWhere, strWhere, _ := buildConditionQuery(whereCondition, " AND", 1)
-	sqlStatement = fmt.Sprintf(sqlStatement, tableName, strWhere)
-
-	rs, err := DBConn.Exec(sqlStatement, arrWhere...)
+	strSet, err := buildSetQuery(newValue, args)
 	if err != nil {
 		log.Error(err)
 		return nil, err
 	}
-
-	return rs, nil
-}
-
-func (p Postgres) GetAllToMap(tableName string, limit int, offset int) ([]map[string]interface{}, error) {
-	sqlStatement := fmt.Sprintf("SELECT * FROM %s", tableName)
-	if limit > -1 {
-		sqlStatement = sqlStatement + fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
-	}
-
-	rows, err := DBConn.Query(sqlStatement)
+	strWhere, err := buildWhere(whereCondition, args)
 	if err != nil {
 		log.Error(err)
 		return nil, err
 	}
-	defer rows.Close()
-
-	colNames, err := rows.Columns()
-	if err != nil {
-		log.Fatal(err)
-		return nil, err
-	}
-	cols := make([]interface{}, len(colNames))
-	colPtrs := make([]interface{}, len(colNames))
-
-	for i := 0; i < len(colNames); i++ {
-		colPtrs[i] = &cols[i]
-	}
+	sqlStatement = fmt.Sprintf(sqlStatement, strTable, strSet, strWhere)
 
-	var myMap = make([]map[string]interface{}, 0)
-	for rows.Next() {
-		err = rows.Scan(colPtrs...)
-		if err != nil {
-			log.Fatal(err)
-			return nil, err
-		}
-		var rowMap = make(map[string]interface{})
-		for i, col := range cols {
-			rowMap[colNames[i]] = col
-		}
-
-		myMap = append(myMap, rowMap)
-	}
+	return p.write(ctx, sqlStatement, args.values, dest)
+}
 
-	return myMap, nil
+func (w writer) Delete(tableName string, whereCondition interface{}) (*WriteResult, error) {
+	return w.DeleteContext(context.Background(), tableName, whereCondition)
 }
 
-func (p Postgres) GetAllToStruct(tableName string, limit int, offset int, respStruct interface{}) (interface{}, error) {
-	sqlStatement := fmt.Sprintf("SELECT * FROM %s", tableName)
-	if limit > -1 {
-		sqlStatement = sqlStatement + fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
-	}
+func (w writer) DeleteContext(ctx context.Context, tableName string, whereCondition interface{}) (*WriteResult, error) {
+	p := w.db
+	sqlStatement := `DELETE FROM %s WHERE %s`
 
-	rows, err := DBConn.Query(sqlStatement)
+	args := &queryArgs{idents: p.identQuoter()}
+	strTable, err := args.idents.quote(tableName)
 	if err != nil {
 		log.Error(err)
 		return nil, err
 	}
-	defer rows.Close()
-
-	colNames, err := rows.Columns()
+	strWhere, err := buildWhere(whereCondition, args)
 	if err != nil {
-		log.Fatal(err)
+		log.Error(err)
 		return nil, err
 	}
+	sqlStatement = fmt.Sprintf(sqlStatement, strTable, strWhere)
 
-	cols := make([]interface{}, len(colNames))
-	colPtrs := make([]interface{}, len(colNames))
-
-	for i := 0; i < len(colNames); i++ {
-		colPtrs[i] = &cols[i]
-	}
+	return p.write(ctx, sqlStatement, args.values, nil)
+}
 
-	attr := reflect.ValueOf(respStruct)
-	attrType := reflect.TypeOf(respStruct)
+func (p *Postgres) GetAllToMap(tableName string, limit int, offset int) ([]map[string]interface{}, error) {
+	return p.GetAllToMapContext(context.Background(), tableName, limit, offset)
+}
 
-	mapAttr := make(map[string]string)
-	mapType := make(map[string]string)
-	for k := 0; k < attr.NumField(); k++ {
-		fieldTag := attrType.Field(k).Tag
-		fieldType := attrType.Field(k).Type.String()
-		dbFieldName, _ := fieldTag.Lookup("db")
-		mapAttr[dbFieldName] = attrType.Field(k).Name
-		mapType[dbFieldName] = fieldType
+func (p *Postgres) GetAllToMapContext(ctx context.Context, tableName string, limit int, offset int) ([]map[string]interface{}, error) {
+	sqlStatement, err := p.selectAllQuery(tableName, limit, offset)
+	if err != nil {
+		return nil, err
 	}
 
-	var arrStruct = make([]interface{}, 0)
-	for rows.Next() {
-		err = rows.Scan(colPtrs...)
-		if err != nil {
-			log.Fatal(err)
-			return nil, err
-		}
+	return p.queryToMap(ctx, sqlStatement, nil)
+}
 
-		newStruct := reflect.New(attrType).Elem()
-		for i, col := range cols {
-			fieldName := mapAttr[colNames[i]]
-			colVal := reflect.ValueOf(col)
-			if colVal.IsValid() && newStruct.FieldByName(fieldName).CanSet() {
-				newStruct.FieldByName(fieldName).Set(reflect.ValueOf(col))
-			}
-		}
+func (p *Postgres) GetAllToStruct(tableName string, limit int, offset int, respStruct interface{}) (interface{}, error) {
+	return p.GetAllToStructContext(context.Background(), tableName, limit, offset, respStruct)
+}
 
-		arrStruct = append(arrStruct, newStruct.Addr().Interface())
+func (p *Postgres) GetAllToStructContext(ctx context.Context, tableName string, limit int, offset int, respStruct interface{}) (interface{}, error) {
+	sqlStatement, err := p.selectAllQuery(tableName, limit, offset)
+	if err != nil {
+		return nil, err
 	}
 
-	return arrStruct, nil
+	return p.queryToStruct(ctx, sqlStatement, nil, respStruct)
 }
 
-func (p Postgres) ExecuteSelectToMap(sqlQuery string, params []interface{}) ([]map[string]interface{}, error) {
-	sqlStatement := sqlQuery
-
-	rows, err := DBConn.Query(sqlStatement, params...)
+func (p *Postgres) selectAllQuery(tableName string, limit int, offset int) (string, error) {
+	strTable, err := p.identQuoter().quote(tableName)
 	if err != nil {
 		log.Error(err)
-		return nil, err
+		return "", err
 	}
-	defer rows.Close()
 
-	colNames, err := rows.Columns()
-	if err != nil {
-		log.Fatal(err)
-		return nil, err
+	sqlStatement := fmt.Sprintf("SELECT * FROM %s", strTable)
+	if limit > -1 {
+		sqlStatement = sqlStatement + fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
 	}
-	cols := make([]interface{}, len(colNames))
-	colPtrs := make([]interface{}, len(colNames))
+	return sqlStatement, nil
+}
 
-	for i := 0; i < len(colNames); i++ {
-		colPtrs[i] = &cols[i]
-	}
+func (p *Postgres) ExecuteSelectToMap(sqlQuery string, params []interface{}) ([]map[string]interface{}, error) {
+	return p.ExecuteSelectToMapContext(context.Background(), sqlQuery, params)
+}
 
-	var myMap = make([]map[string]interface{}, 0)
-	for rows.Next() {
-		err = rows.Scan(colPtrs...)
-		if err != nil {
-			log.Fatal(err)
-			return nil, err
-		}
-		var rowMap = make(map[string]interface{})
-		for i, col := range cols {
-			rowMap[colNames[i]] = col
-		}
+func (p *Postgres) ExecuteSelectToMapContext(ctx context.Context, sqlQuery string, params []interface{}) ([]map[string]interface{}, error) {
+	return p.queryToMap(ctx, sqlQuery, params)
+}
 
-		myMap = append(myMap, rowMap)
-	}
+func (p *Postgres) ExecuteSelectToStruct(sqlQuery string, params []interface{}, respStruct interface{}) ([]interface{}, error) {
+	return p.ExecuteSelectToStructContext(context.Background(), sqlQuery, params, respStruct)
+}
 
-	return myMap, nil
+func (p *Postgres) ExecuteSelectToStructContext(ctx context.Context, sqlQuery string, params []interface{}, respStruct interface{}) ([]interface{}, error) {
+	return p.queryToStruct(ctx, sqlQuery, params, respStruct)
 }
 
-func (p Postgres) ExecuteSelectToStruct(sqlQuery string, params []interface{}, respStruct interface{}) ([]interface{}, error) {
-	sqlStatement := sqlQuery
+func (w writer) Execute(sqlExecute string, params []interface{}) (*WriteResult, error) {
+	return w.ExecuteContext(context.Background(), sqlExecute, params)
+}
 
-	rows, err := DBConn.Query(sqlStatement, params...)
+func (w writer) ExecuteContext(ctx context.Context, sqlExecute string, params []interface{}) (*WriteResult, error) {
+	p := w.db
+	return p.execResult(ctx, sqlExecute, params)
+}
+
+func (p *Postgres) exec(ctx context.Context, sqlStatement string, params []interface{}) (sql.Result, error) {
+	db, err := p.conn()
 	if err != nil {
-		log.Error(err)
 		return nil, err
 	}
-	defer rows.Close()
 
-	colNames, err := rows.Columns()
+	rs, err := db.ExecContext(ctx, sqlStatement, params...)
 	if err != nil {
-		log.Fatal(err)
+		log.Error(err)
 		return nil, err
 	}
 
-	cols := make([]interface{}, len(colNames))
-	colPtrs := make([]interface{}, len(colNames))
-
-	for i := 0; i < len(colNames); i++ {
-		colPtrs[i] = &cols[i]
-	}
-
-	attr := reflect.ValueOf(respStruct)
-	attrType := reflect.TypeOf(respStruct)
+	return rs, nil
+}
 
-	mapAttr := make(map[string]string)
-	mapType := make(map[string]string)
-	for k := 0; k < attr.NumField(); k++ {
-		fieldTag := attrType.Field(k).Tag
-		fieldType := attrType.Field(k).Type.String()
-		dbFieldName, _ := fieldTag.Lookup("db")
-		mapAttr[dbFieldName] = attrType.Field(k).Name
-		mapType[dbFieldName] = fieldType
+func (p *Postgres) queryToMap(ctx context.Context, sqlStatement string, params []interface{}) ([]map[string]interface{}, error) {
+	var myMap = make([]map[string]interface{}, 0)
+	err := p.ForEachMapContext(ctx, sqlStatement, params, func(row map[string]interface{}) error {
+		myMap = append(myMap, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	var arrStruct = make([]interface{}, 0)
-	for rows.Next() {
-		err = rows.Scan(colPtrs...)
-		if err != nil {
-			log.Fatal(err)
-			return nil, err
-		}
-
-		newStruct := reflect.New(attrType).Elem()
-		for i, col := range cols {
-			fieldName := mapAttr[colNames[i]]
-			colVal := reflect.ValueOf(col)
-			if colVal.IsValid() && newStruct.FieldByName(fieldName).CanSet() {
-				newStruct.FieldByName(fieldName).Set(colVal)
-			}
-		}
-
-		arrStruct = append(arrStruct, newStruct.Addr().Interface())
-	}
-	return arrStruct, nil
+	return myMap, nil
 }
 
-func (p Postgres) Execute(sqlExecute string, params []interface{}) (interface{}, error) {
-	rs, err := DBConn.Exec(sqlExecute, params...)
+func (p *Postgres) queryToStruct(ctx context.Context, sqlStatement string, params []interface{}, respStruct interface{}) ([]interface{}, error) {
+	var arrStruct = make([]interface{}, 0)
+	err := p.ForEachStructContext(ctx, sqlStatement, params, respStruct, func(row interface{}) error {
+		arrStruct = append(arrStruct, row)
+		return nil
+	})
 	if err != nil {
-		log.Error(err)
 		return nil, err
 	}
 
-	return rs, nil
+	return arrStruct, nil
 }
 
-func convertMapToParams(mapData map[string]interface{}) ([]interface{}, string, string) {
-	var mapLen int = len(mapData)
-	var arrValues []interface{} = make([]interface{}, 0)
-	var strParams string = ""
-	var strValues string = ""
-	var loopIndex int = 1
+func convertMapToParams(mapData map[string]interface{}) ([]interface{}, []string, string) {
+	var listColumns []string = make([]string, 0, len(mapData))
+	var arrValues []interface{} = make([]interface{}, 0, len(mapData))
+	var strValues []string = make([]string, 0, len(mapData))
 
-	for k, v := range mapData {
+	for k := range mapData {
+		listColumns = append(listColumns, k)
+	}
+	sort.Strings(listColumns)
+
+	for i, k := range listColumns {
+		v := mapData[k]
 		if reflect.ValueOf(v).Kind() == reflect.Map || reflect.ValueOf(v).Kind() == reflect.Array {
 			v, _ = json.Marshal(v)
 		}
 
 		arrValues = append(arrValues, v)
-		if loopIndex == mapLen {
-			strParams = strParams + k
-			strValues = strValues + fmt.Sprintf("$%d", loopIndex)
-		} else {
-			strParams = strParams + k + ", "
-			strValues = strValues + fmt.Sprintf("$%d, ", loopIndex)
-		}
-
-		loopIndex++
+		strValues = append(strValues, fmt.Sprintf("$%d", i+1))
 	}
 
-	return arrValues, strParams, strValues
+	return arrValues, listColumns, strings.Join(strValues, ", ")
 }
 
-func convertStructToParams(reqStruct interface{}) ([]interface{}, string, string) {
+// convertStructToParams returns the values, fields and placeholders of the
+// columns reqStruct writes in an INSERT.
+func convertStructToParams(reqStruct interface{}) ([]interface{}, []structField, string) {
 	var arrValues []interface{} = make([]interface{}, 0)
-	var strParams string = ""
-	var strValues string = ""
-	var loopIndex int = 1
+	var listFields []structField = make([]structField, 0)
+	var strValues []string = make([]string, 0)
 
-	attr := reflect.ValueOf(reqStruct)
-	attrType := reflect.TypeOf(reqStruct)
-	if attr.Kind() == reflect.Ptr {
-		attr = attr.Elem()
-		attrType = attrType.Elem()
-	}
+	attr := reflect.Indirect(reflect.ValueOf(reqStruct))
 
-	for k := 0; k < attr.NumField(); k++ {
-		fieldTag := attrType.Field(k).Tag
-		dbFieldName, _ := fieldTag.Lookup("db")
-		if dbFieldName == "" {
+	for _, field := range structFields(attr.Type()) {
+		if !field.insertable() {
 			continue
 		}
-
-		fieldName := attrType.Field(k).Name
-		var valueInput interface{} = (reflect.Indirect(attr).FieldByName(fieldName)).Interface()
-
-		if reflect.ValueOf(valueInput).Kind() == reflect.Map ||
-			reflect.ValueOf(valueInput).Kind() == reflect.Array ||
-			reflect.ValueOf(valueInput).Kind() == reflect.Struct ||
-			reflect.ValueOf(valueInput).Kind() == reflect.Slice {
-			valueInput, _ = json.Marshal(valueInput)
+		value, ok := fieldParam(attr, field)
+		if !ok {
+			continue
 		}
 
-		arrValues = append(arrValues, valueInput)
-		strParams = strParams + dbFieldName + ", "
-		strValues = strValues + fmt.Sprintf("$%d, ", loopIndex)
-
-		loopIndex++
+		arrValues = append(arrValues, value)
+		listFields = append(listFields, field)
+		strValues = append(strValues, fmt.Sprintf("$%d", len(arrValues)))
 	}
 
-	strParams = strings.TrimSuffix(strings.Trim(strParams, " "), ",")
-	strValues = strings.TrimSuffix(strings.Trim(strValues, " "), ",")
+	return arrValues, listFields, strings.Join(strValues, ", ")
+}
 
-	return arrValues, strParams, strValues
+// quoteTableAndColumns quotes the table name and the comma separated column list of an INSERT.
+func quoteTableAndColumns(idents identQuoter, tableName string, listColumns []string) (string, string, error) {
+	strTable, err := idents.quote(tableName)
+	if err != nil {
+		return "", "", err
+	}
+	strColumns, err := idents.quoteColumns(listColumns)
+	if err != nil {
+		return "", "", err
+	}
+	return strTable, strColumns, nil
 }
 
-func convertListMapToParams(listMapData []map[string]interface{}, listColumns []string) ([]interface{}, string) {
+// convertListMapToParams builds the VALUES rows of listMapData. A key missing
+// from a row is written as NULL, or as DEFAULT when missingAsDefault is set.
+func convertListMapToParams(listMapData []map[string]interface{}, listColumns []string, missingAsDefault bool) ([]interface{}, string) {
 	var arrValues []interface{}
 	lenColumns := len(listColumns)
 	values := ""
@@ -498,71 +552,65 @@ func convertListMapToParams(listMapData []map[string]interface{}, listColumns []
 			values = values + ", ("
 		}
 		for j := 0; j < lenColumns; j++ {
-			v := listMapData[i][listColumns[j]]
-			if reflect.ValueOf(v).Kind() == reflect.Map || reflect.ValueOf(v).Kind() == reflect.Array {
-				v, _ = json.Marshal(v)
+			if j > 0 {
+				values = values + ", "
 			}
 
-			arrValues = append(arrValues, v)
-			if j == lenColumns-1 {
-				values = values + ", " + fmt.Sprintf("$%d", index) + ")"
-			} else if j == 0 {
-				values = values + fmt.Sprintf("$%d", index)
+			v, ok := listMapData[i][listColumns[j]]
+			if !ok && missingAsDefault {
+				values = values + "DEFAULT"
 			} else {
-				values = values + ", " + fmt.Sprintf("$%d", index)
+				if reflect.ValueOf(v).Kind() == reflect.Map || reflect.ValueOf(v).Kind() == reflect.Array {
+					v, _ = json.Marshal(v)
+				}
+				arrValues = append(arrValues, v)
+				values = values + fmt.Sprintf("$%d", index)
+				index++
+			}
+
+			if j == lenColumns-1 {
+				values = values + ")"
 			}
-			index++
 		}
 	}
 	return arrValues, values
 }
 
-func getListColumns(listMapData []map[string]interface{}) ([]string, string) {
-	var maxLen int = 0
-	var listColumnsText string = ""
-	var listColumns []string
-	var is_reset bool = false
-
-	for i := 0; i < len(listMapData); i++ {
-		for k, _ := range listMapData[i] {
-			lenMap := len(listMapData[i])
-			if lenMap > maxLen {
-				maxLen = lenMap
-				listColumnsText = ""
-				listColumns = []string{}
-				is_reset = true
-			}
-
-			if is_reset {
+// getListColumns returns the union of the keys of every row, sorted.
+func getListColumns(listMapData []map[string]interface{}) []string {
+	seen := make(map[string]bool)
+	listColumns := make([]string, 0)
+	for _, row := range listMapData {
+		for k := range row {
+			if !seen[k] {
+				seen[k] = true
 				listColumns = append(listColumns, k)
 			}
 		}
-		is_reset = false
 	}
-
-	listColumnsText = strings.Join(listColumns, ", ")
-
-	return listColumns, listColumnsText
+	sort.Strings(listColumns)
+	return listColumns
 }
 
-func buildConditionQuery(mapData map[string]interface{}, charSplit string, loopIndex int) ([]interface{}, string, int) {
-	var mapLen int = len(mapData) + (loopIndex - 1)
-	var result string = ""
-	var arrValues []interface{} = make([]interface{}, 0)
+func buildSetQuery(mapData map[string]interface{}, args *queryArgs) (string, error) {
+	columns := make([]string, 0, len(mapData))
+	for k := range mapData {
+		columns = append(columns, k)
+	}
+	sort.Strings(columns)
 
-	for k, v := range mapData {
-		if reflect.ValueOf(v).Kind() == reflect.Map || reflect.ValueOf(v).Kind() == reflect.Array {
-			v, _ = json.Marshal(v)
+	sets := make([]string, 0, len(columns))
+	for _, col := range columns {
+		quotedCol, err := args.idents.quoteColumn(col)
+		if err != nil {
+			return "", err
 		}
-		arrValues = append(arrValues, v)
-		if loopIndex == mapLen {
-			result = result + fmt.Sprintf("%s=$%d", k, loopIndex)
-		} else {
-			result = result + fmt.Sprintf("%s=$%d%s ", k, loopIndex, charSplit)
+		placeholder, err := args.add(mapData[col])
+		if err != nil {
+			return "", err
 		}
-
-		loopIndex++
+		sets = append(sets, fmt.Sprintf("%s=%s", quotedCol, placeholder))
 	}
 
-	return arrValues, result, loopIndex
+	return strings.Join(sets, ", "), nil
 }
@@ -0,0 +1,305 @@
+package godal
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+// SelectBuilder builds a SELECT statement step by step, started with From:
+//
+//	db.From("users").Select("id", "name").Where(Gt("age", 18)).OrderBy("created_at DESC").Limit(10)
+//
+// A builder can be passed as a value to In, Eq, Exists or FromSubquery to
+// nest it as a sub-query. The results go through the same map and struct
+// scanning as ExecuteSelectToMap and ExecuteSelectToStruct.
+type SelectBuilder struct {
+	db       *Postgres
+	from     string
+	fromSub  *SelectBuilder
+	columns  []sqlExpr
+	distinct bool
+	joins    []joinClause
+	where    []Condition
+	groupBy  []sqlExpr
+	having   []Condition
+	orderBy  []sqlExpr
+	limit    int
+	offset   int
+}
+
+// sqlExpr is an item of Select, GroupBy or OrderBy. Raw items are written as
+// given, the others are quoted as identifiers.
+type sqlExpr struct {
+	expr string
+	raw  bool
+}
+
+func sqlExprs(exprs []string, raw bool) []sqlExpr {
+	items := make([]sqlExpr, len(exprs))
+	for i, expr := range exprs {
+		items[i] = sqlExpr{expr: expr, raw: raw}
+	}
+	return items
+}
+
+type joinClause struct {
+	kind      string
+	tableName string
+	on        string
+	// raw is a whole join clause written as given, set by JoinRaw.
+	raw string
+}
+
+// From starts a SELECT on tableName, which may carry an alias ("users u" or "users AS u").
+func (p *Postgres) From(tableName string) *SelectBuilder {
+	return &SelectBuilder{db: p, from: tableName, limit: -1}
+}
+
+// FromSubquery selects from the result of sub, named alias.
+func (p *Postgres) FromSubquery(sub *SelectBuilder, alias string) *SelectBuilder {
+	return &SelectBuilder{db: p, from: alias, fromSub: sub, limit: -1}
+}
+
+// Select adds selected columns, optionally qualified or aliased ("u.name AS n").
+// All columns are selected when neither Select nor SelectRaw is called.
+func (b *SelectBuilder) Select(columns ...string) *SelectBuilder {
+	b.columns = append(b.columns, sqlExprs(columns, false)...)
+	return b
+}
+
+// SelectRaw adds selected SQL expressions written as given, e.g.
+// "count(*) AS total". They are trusted SQL, never build them from user input.
+func (b *SelectBuilder) SelectRaw(exprs ...string) *SelectBuilder {
+	b.columns = append(b.columns, sqlExprs(exprs, true)...)
+	return b
+}
+
+// Distinct turns the statement into SELECT DISTINCT.
+func (b *SelectBuilder) Distinct() *SelectBuilder {
+	b.distinct = true
+	return b
+}
+
+// Join adds an INNER JOIN of tableName on the given SQL condition.
+func (b *SelectBuilder) Join(tableName string, on string) *SelectBuilder {
+	return b.join("JOIN", tableName, on)
+}
+
+// LeftJoin adds a LEFT JOIN of tableName on the given SQL condition.
+func (b *SelectBuilder) LeftJoin(tableName string, on string) *SelectBuilder {
+	return b.join("LEFT JOIN", tableName, on)
+}
+
+// RightJoin adds a RIGHT JOIN of tableName on the given SQL condition.
+func (b *SelectBuilder) RightJoin(tableName string, on string) *SelectBuilder {
+	return b.join("RIGHT JOIN", tableName, on)
+}
+
+// JoinRaw adds a join clause written as given, for the join targets that are
+// not a table name, e.g. "LEFT JOIN LATERAL (SELECT ...) AS l ON true". It is
+// trusted SQL, never build it from user input.
+func (b *SelectBuilder) JoinRaw(clause string) *SelectBuilder {
+	b.joins = append(b.joins, joinClause{raw: clause})
+	return b
+}
+
+func (b *SelectBuilder) join(kind string, tableName string, on string) *SelectBuilder {
+	b.joins = append(b.joins, joinClause{kind: kind, tableName: tableName, on: on})
+	return b
+}
+
+// Where adds conditions, every call is joined with AND.
+func (b *SelectBuilder) Where(conds ...Condition) *SelectBuilder {
+	b.where = append(b.where, conds...)
+	return b
+}
+
+// GroupBy adds GROUP BY columns.
+func (b *SelectBuilder) GroupBy(columns ...string) *SelectBuilder {
+	b.groupBy = append(b.groupBy, sqlExprs(columns, false)...)
+	return b
+}
+
+// GroupByRaw adds GROUP BY SQL expressions written as given.
+func (b *SelectBuilder) GroupByRaw(exprs ...string) *SelectBuilder {
+	b.groupBy = append(b.groupBy, sqlExprs(exprs, true)...)
+	return b
+}
+
+// Having adds HAVING conditions, usually built with Raw("count(*) > ?", n).
+func (b *SelectBuilder) Having(conds ...Condition) *SelectBuilder {
+	b.having = append(b.having, conds...)
+	return b
+}
+
+// OrderBy adds ORDER BY columns with an optional direction, such as "created_at DESC".
+func (b *SelectBuilder) OrderBy(exprs ...string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, sqlExprs(exprs, false)...)
+	return b
+}
+
+// OrderByRaw adds ORDER BY SQL expressions written as given, e.g. "lower(name)".
+func (b *SelectBuilder) OrderByRaw(exprs ...string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, sqlExprs(exprs, true)...)
+	return b
+}
+
+// Limit sets the LIMIT, a negative value removes it.
+func (b *SelectBuilder) Limit(limit int) *SelectBuilder {
+	b.limit = limit
+	return b
+}
+
+// Offset sets the OFFSET.
+func (b *SelectBuilder) Offset(offset int) *SelectBuilder {
+	b.offset = offset
+	return b
+}
+
+// ToSQL returns the statement and its parameters.
+func (b *SelectBuilder) ToSQL() (string, []interface{}, error) {
+	args := &queryArgs{idents: b.db.identQuoter()}
+	sqlStatement, err := b.build(args)
+	if err != nil {
+		return "", nil, err
+	}
+	return sqlStatement, args.values, nil
+}
+
+// build renders the statement, numbering its placeholders after those already
+// in args. Table names, aliases and the columns of Select, GroupBy and OrderBy
+// are quoted and anything else in them is rejected, the Raw expressions are
+// written as given.
+func (b *SelectBuilder) build(args *queryArgs) (string, error) {
+	var sb strings.Builder
+
+	sb.WriteString("SELECT ")
+	if b.distinct {
+		sb.WriteString("DISTINCT ")
+	}
+	if len(b.columns) == 0 {
+		sb.WriteString("*")
+	} else {
+		columns, err := quoteEach(b.columns, args.idents.quoteIfIdent)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(strings.Join(columns, ", "))
+	}
+
+	sb.WriteString(" FROM ")
+	if b.fromSub != nil {
+		subSQL, err := b.fromSub.build(args)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString("(" + subSQL + ") " + pq.QuoteIdentifier(b.from))
+	} else {
+		from, err := args.idents.quoteIfIdent(b.from)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(from)
+	}
+
+	for _, join := range b.joins {
+		if join.raw != "" {
+			sb.WriteString(" " + join.raw)
+			continue
+		}
+		tableName, err := args.idents.quoteIfIdent(join.tableName)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(fmt.Sprintf(" %s %s ON %s", join.kind, tableName, join.on))
+	}
+
+	if len(b.where) > 0 {
+		strWhere, err := And(b.where...).toSQL(args)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(" WHERE " + strWhere)
+	}
+
+	if len(b.groupBy) > 0 {
+		groupBy, err := quoteEach(b.groupBy, args.idents.quoteIfIdent)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(" GROUP BY " + strings.Join(groupBy, ", "))
+	}
+
+	if len(b.having) > 0 {
+		strHaving, err := And(b.having...).toSQL(args)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(" HAVING " + strHaving)
+	}
+
+	if len(b.orderBy) > 0 {
+		orderBy, err := quoteEach(b.orderBy, args.idents.quoteOrderBy)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(" ORDER BY " + strings.Join(orderBy, ", "))
+	}
+
+	if b.limit > -1 {
+		sb.WriteString(fmt.Sprintf(" LIMIT %d", b.limit))
+	}
+	if b.offset > 0 {
+		sb.WriteString(fmt.Sprintf(" OFFSET %d", b.offset))
+	}
+
+	return sb.String(), nil
+}
+
+func quoteEach(items []sqlExpr, quote func(string) (string, error)) ([]string, error) {
+	quoted := make([]string, len(items))
+	for i, item := range items {
+		if item.raw {
+			quoted[i] = item.expr
+			continue
+		}
+		expr, err := quote(item.expr)
+		if err != nil {
+			return nil, err
+		}
+		quoted[i] = expr
+	}
+	return quoted, nil
+}
+
+// ToMap runs the statement and returns the rows as maps.
+func (b *SelectBuilder) ToMap() ([]map[string]interface{}, error) {
+	return b.ToMapContext(context.Background())
+}
+
+func (b *SelectBuilder) ToMapContext(ctx context.Context) ([]map[string]interface{}, error) {
+	sqlStatement, params, err := b.ToSQL()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return b.db.queryToMap(ctx, sqlStatement, params)
+}
+
+// ToStruct runs the statement and returns the rows as pointers to new copies of respStruct.
+func (b *SelectBuilder) ToStruct(respStruct interface{}) ([]interface{}, error) {
+	return b.ToStructContext(context.Background(), respStruct)
+}
+
+func (b *SelectBuilder) ToStructContext(ctx context.Context, respStruct interface{}) ([]interface{}, error) {
+	sqlStatement, params, err := b.ToSQL()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return b.db.queryToStruct(ctx, sqlStatement, params, respStruct)
+}
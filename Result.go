@@ -0,0 +1,71 @@
+package godal
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WriteResult is the result of the write methods of Writer. It implements
+// sql.Result.
+//
+//	rs, err := db.Writer().Update("users", values, where)
+//	n, _ := rs.RowsAffected()
+type WriteResult struct {
+	// Rows are the rows of the RETURNING clause, set on a Returning handle.
+	Rows []map[string]interface{}
+	// IDs are the values of the returned column, set on a Returning handle
+	// that names exactly one column, e.g. Returning("id").
+	IDs []interface{}
+	// Statement is the SQL that was run. For a batch split into chunks it is
+	// the statement of the last chunk.
+	Statement string
+	// Duration is the time the statement took, summed over the chunks of a batch.
+	Duration time.Duration
+
+	rowsAffected int64
+}
+
+// RowsAffected returns the number of rows inserted, updated or deleted.
+func (r *WriteResult) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+// LastInsertId is not supported by postgres, use a Returning handle.
+func (r *WriteResult) LastInsertId() (int64, error) {
+	return 0, errors.New("godal: LastInsertId is not supported by postgres, use Returning")
+}
+
+// writer implements IWriter on top of a handle.
+type writer struct {
+	db *Postgres
+}
+
+// Writer returns the write methods of the handle that return a *WriteResult.
+// On a Returning or transaction handle they return the rows or run in the
+// transaction like the handle itself.
+func (p *Postgres) Writer() IWriter {
+	return writer{db: p}
+}
+
+// add merges the result of a chunk into r.
+func (r *WriteResult) add(chunk *WriteResult) {
+	r.rowsAffected += chunk.rowsAffected
+	r.Rows = append(r.Rows, chunk.Rows...)
+	r.IDs = append(r.IDs, chunk.IDs...)
+	r.Statement = chunk.Statement
+	r.Duration += chunk.Duration
+}
+
+// execResult runs a statement that returns no rows.
+func (p *Postgres) execResult(ctx context.Context, sqlStatement string, params []interface{}) (*WriteResult, error) {
+	start := time.Now()
+	rs, err := p.exec(ctx, sqlStatement, params)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &WriteResult{Statement: sqlStatement, Duration: time.Since(start)}
+	result.rowsAffected, _ = rs.RowsAffected()
+	return result, nil
+}
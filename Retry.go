@@ -0,0 +1,82 @@
+package godal
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+	defaultMultiplier     = 2
+)
+
+// RetryPolicy describes how many times an operation is attempted and how long
+// to wait between attempts. The zero value means a single attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the delay before the second attempt. Default 100ms.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts. Default 5s.
+	MaxBackoff time.Duration
+	// Multiplier grows the delay after every attempt. Default 2.
+	Multiplier float64
+	// Jitter randomises each delay by up to this fraction (0..1) of its value.
+	Jitter float64
+}
+
+func (r RetryPolicy) attempts() int {
+	if r.MaxAttempts < 1 {
+		return 1
+	}
+	return r.MaxAttempts
+}
+
+// backoff returns the delay to wait after the given failed attempt (1-based).
+func (r RetryPolicy) backoff(attempt int) time.Duration {
+	delay := r.InitialBackoff
+	if delay <= 0 {
+		delay = defaultInitialBackoff
+	}
+	maxDelay := r.MaxBackoff
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxBackoff
+	}
+	multiplier := r.Multiplier
+	if multiplier < 1 {
+		multiplier = defaultMultiplier
+	}
+
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay = time.Duration(float64(delay) * multiplier)
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	if r.Jitter > 0 {
+		jitter := r.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		spread := float64(delay) * jitter
+		delay = time.Duration(float64(delay) - spread + rand.Float64()*2*spread)
+	}
+
+	return delay
+}
+
+// wait sleeps for the backoff of the given attempt or until ctx is done.
+func (r RetryPolicy) wait(ctx context.Context, attempt int) error {
+	timer := time.NewTimer(r.backoff(attempt))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
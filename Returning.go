@@ -0,0 +1,84 @@
+package godal
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Returning returns a copy of the handle whose write methods add a RETURNING
+// clause with columns, or RETURNING * when none are given. They then return
+// the affected rows, with the server-generated ids, defaults and trigger-set
+// columns: in WriteResult.Rows from Writer, as []map[string]interface{} from
+// the deprecated IDatabase methods.
+// CreateWithStruct and CreateOrUpdate also write the returned row into
+// reqStruct when it is a pointer to a struct.
+//
+//	user := &User{Name: "alice"}
+//	_, err := db.Returning().CreateWithStruct("users", user) // user.ID is set
+func (p *Postgres) Returning(columns ...string) IDatabase {
+	if len(columns) == 0 {
+		columns = []string{"*"}
+	}
+	handle := *p
+	handle.returning = columns
+	return &handle
+}
+
+func (p *Postgres) returningClause() (string, error) {
+	if len(p.returning) == 1 && p.returning[0] == "*" {
+		return "RETURNING *", nil
+	}
+	strColumns, err := p.identQuoter().quoteList(p.returning)
+	if err != nil {
+		return "", err
+	}
+	return "RETURNING " + strColumns, nil
+}
+
+// write runs a write statement. On a Returning handle the result holds the
+// rows of the RETURNING clause, and the first one is written into dest when
+// dest is a pointer to a struct.
+func (p *Postgres) write(ctx context.Context, sqlStatement string, params []interface{}, dest interface{}) (*WriteResult, error) {
+	if p.returning == nil {
+		return p.execResult(ctx, sqlStatement, params)
+	}
+
+	strReturning, err := p.returningClause()
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	sqlStatement = strings.TrimRight(sqlStatement, " \t\n") + "\n\t\t" + strReturning
+
+	destValue := reflect.ValueOf(dest)
+	scanDest := destValue.Kind() == reflect.Ptr && !destValue.IsNil() && destValue.Elem().Kind() == reflect.Struct
+
+	singleColumn := len(p.returning) == 1 && p.returning[0] != "*"
+	start := time.Now()
+	result := &WriteResult{Rows: make([]map[string]interface{}, 0), Statement: sqlStatement}
+	err = p.forEach(ctx, sqlStatement, params, func(r *Rows) error {
+		if scanDest && len(result.Rows) == 0 {
+			if err := r.scanStruct(destValue.Elem()); err != nil {
+				return err
+			}
+		}
+		result.Rows = append(result.Rows, r.Map())
+		// The name of the result column may differ from the returned
+		// expression, e.g. "users.id", so IDs are read by position.
+		if singleColumn && len(r.Columns()) == 1 {
+			result.IDs = append(result.IDs, r.cols[0])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result.Duration = time.Since(start)
+	result.rowsAffected = int64(len(result.Rows))
+
+	return result, nil
+}
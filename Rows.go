@@ -0,0 +1,202 @@
+package godal
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Rows streams the result of a query one row at a time instead of buffering
+// it. Always Close it; to abandon a large result early, also cancel the
+// context of the query so the server stops sending rows.
+//
+//	rows, err := db.ExecuteSelectRowsContext(ctx, "SELECT * FROM users", nil)
+//	...
+//	defer rows.Close()
+//	for rows.Next() {
+//		user := User{}
+//		if err := rows.Scan(&user); err != nil {
+//			...
+//		}
+//	}
+//	err = rows.Err()
+type Rows struct {
+	rows     *sql.Rows
+	colNames []string
+	cols     []interface{}
+	colPtrs  []interface{}
+	err      error
+
+	// plan caches the column fields of the last struct type scanned.
+	planType   reflect.Type
+	planFields []*structField
+}
+
+func newRows(rows *sql.Rows) (*Rows, error) {
+	colNames, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return nil, err
+	}
+
+	r := &Rows{
+		rows:     rows,
+		colNames: colNames,
+		cols:     make([]interface{}, len(colNames)),
+		colPtrs:  make([]interface{}, len(colNames)),
+	}
+	for i := range r.cols {
+		r.colPtrs[i] = &r.cols[i]
+	}
+	return r, nil
+}
+
+// Next reads the next row, it returns false at the end of the result or on error.
+func (r *Rows) Next() bool {
+	if r.err != nil || !r.rows.Next() {
+		return false
+	}
+	if err := r.rows.Scan(r.colPtrs...); err != nil {
+		r.err = err
+		return false
+	}
+	return true
+}
+
+// Columns returns the column names of the result.
+func (r *Rows) Columns() []string {
+	return r.colNames
+}
+
+// Map returns the current row as a new map of column name to value.
+func (r *Rows) Map() map[string]interface{} {
+	rowMap := make(map[string]interface{}, len(r.colNames))
+	for i, col := range r.cols {
+		rowMap[r.colNames[i]] = col
+	}
+	return rowMap
+}
+
+// Scan stores the current row into dest, a pointer to a struct mapped with db tags.
+func (r *Rows) Scan(dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("godal: Scan needs a non-nil pointer to a struct, got %T", dest)
+	}
+	return r.scanStruct(v.Elem())
+}
+
+func (r *Rows) scanStruct(dst reflect.Value) error {
+	if r.planType != dst.Type() {
+		r.planType = dst.Type()
+		r.planFields = planOf(dst.Type()).columnFields(r.colNames)
+	}
+	return scanStructRow(dst, r.planFields, r.colNames, r.cols)
+}
+
+// Err returns the error that stopped Next, if any.
+func (r *Rows) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.rows.Err()
+}
+
+// Close releases the connection of the result.
+func (r *Rows) Close() error {
+	return r.rows.Close()
+}
+
+func (p *Postgres) ExecuteSelectRows(sqlQuery string, params []interface{}) (*Rows, error) {
+	return p.ExecuteSelectRowsContext(context.Background(), sqlQuery, params)
+}
+
+func (p *Postgres) ExecuteSelectRowsContext(ctx context.Context, sqlQuery string, params []interface{}) (*Rows, error) {
+	db, err := p.conn()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, sqlQuery, params...)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	r, err := newRows(rows)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return r, nil
+}
+
+func (p *Postgres) ForEachMap(sqlQuery string, params []interface{}, fn func(row map[string]interface{}) error) error {
+	return p.ForEachMapContext(context.Background(), sqlQuery, params, fn)
+}
+
+// ForEachMapContext streams the rows of sqlQuery to fn as maps. It stops and
+// returns the error of fn as soon as fn fails, cancelling the query. On a
+// transaction handle the query is not cancelled, which would abort the
+// transaction, and the rest of the result is read and dropped instead: use
+// DeclareCursor to stop early through a large result inside a transaction.
+func (p *Postgres) ForEachMapContext(ctx context.Context, sqlQuery string, params []interface{}, fn func(row map[string]interface{}) error) error {
+	return p.forEach(ctx, sqlQuery, params, func(r *Rows) error {
+		return fn(r.Map())
+	})
+}
+
+func (p *Postgres) ForEachStruct(sqlQuery string, params []interface{}, respStruct interface{}, fn func(row interface{}) error) error {
+	return p.ForEachStructContext(context.Background(), sqlQuery, params, respStruct, fn)
+}
+
+// ForEachStructContext streams the rows of sqlQuery to fn, each as a pointer
+// to a new copy of respStruct. It stops and returns the error of fn as soon as
+// fn fails, cancelling the query outside a transaction like ForEachMapContext.
+func (p *Postgres) ForEachStructContext(ctx context.Context, sqlQuery string, params []interface{}, respStruct interface{}, fn func(row interface{}) error) error {
+	return p.forEach(ctx, sqlQuery, params, structRowFunc(respStruct, fn))
+}
+
+// structRowFunc adapts fn to receive every row as a pointer to a new copy of respStruct.
+func structRowFunc(respStruct interface{}, fn func(row interface{}) error) func(r *Rows) error {
+	attrType := reflect.TypeOf(respStruct)
+	return func(r *Rows) error {
+		newStruct := reflect.New(attrType).Elem()
+		if err := r.scanStruct(newStruct); err != nil {
+			return err
+		}
+		return fn(newStruct.Addr().Interface())
+	}
+}
+
+func (p *Postgres) forEach(ctx context.Context, sqlQuery string, params []interface{}, fn func(r *Rows) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	rows, err := p.ExecuteSelectRowsContext(ctx, sqlQuery, params)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			// Cancel before Close so the server stops sending the rest of the
+			// result. Inside a transaction the cancel would abort the whole
+			// transaction, so Close drains the result instead.
+			if p.tx == nil {
+				cancel()
+			}
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Error(err)
+		return err
+	}
+	return nil
+}
@@ -1,5 +1,7 @@
 package godal
 
+import "database/sql"
+
 type Postgres struct {
 	Host        string
 	Port        string
@@ -9,4 +11,21 @@ type Postgres struct {
 	SSLMode     string
 	MaxIdleConn int32
 	MaxOpenConn int32
+
+	// ConnectRetry controls how Connect retries the initial ping while the
+	// database is starting up. The zero value pings once.
+	ConnectRetry RetryPolicy
+
+	// StrictIdentifiers rejects generated table and column names that are not
+	// in AllowedIdentifiers, instead of only quoting them. A schema-qualified
+	// name is accepted when it is listed as a whole or every part is listed.
+	StrictIdentifiers  bool
+	AllowedIdentifiers []string
+
+	// db is the connection pool owned by this instance, set by Connect.
+	db *sql.DB
+	// tx is set on the transaction-scoped copies returned by Begin.
+	tx *txScope
+	// returning is set on the copies returned by Returning.
+	returning []string
 }
@@ -0,0 +1,209 @@
+package godal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+	log "github.com/sirupsen/logrus"
+)
+
+// TxOptions configures a transaction started by Begin or WithTx.
+// A nil *TxOptions uses the server defaults.
+type TxOptions struct {
+	// Isolation is the isolation level, sql.LevelDefault keeps the server setting.
+	Isolation sql.IsolationLevel
+	// ReadOnly starts a READ ONLY transaction.
+	ReadOnly bool
+	// Retry replays the whole WithTx function when the transaction fails with a
+	// serialization failure (40001) or a deadlock (40P01). The zero value does not retry.
+	Retry RetryPolicy
+	// OnRetry, when set, is called before each replay with the failed attempt (1-based) and its error.
+	OnRetry func(attempt int, err error)
+}
+
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+func (o *TxOptions) sqlOptions() *sql.TxOptions {
+	if o == nil {
+		return nil
+	}
+	return &sql.TxOptions{Isolation: o.Isolation, ReadOnly: o.ReadOnly}
+}
+
+// txScope is the transaction a handle runs in. Scopes begun from a
+// transaction-scoped handle share its *sql.Tx and are backed by a savepoint.
+type txScope struct {
+	tx *sql.Tx
+	// savepoint is empty for the outermost transaction.
+	savepoint string
+	// savepoints counts the savepoints created in tx, shared by all its scopes.
+	savepoints *int
+	done       bool
+}
+
+// Begin a transaction. On a transaction-scoped handle it creates a SAVEPOINT
+// instead; opts are then ignored since the outer transaction settings apply.
+func (p *Postgres) Begin(ctx context.Context, opts *TxOptions) (ITx, error) {
+	if p.tx != nil {
+		return p.beginSavepoint(ctx)
+	}
+	if p.db == nil {
+		log.Error(ErrNotConnected)
+		return nil, ErrNotConnected
+	}
+
+	tx, err := p.db.BeginTx(ctx, opts.sqlOptions())
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	handle := *p
+	handle.tx = &txScope{tx: tx, savepoints: new(int)}
+	return &handle, nil
+}
+
+func (p *Postgres) beginSavepoint(ctx context.Context) (ITx, error) {
+	if p.tx.done {
+		return nil, sql.ErrTxDone
+	}
+
+	*p.tx.savepoints++
+	name := fmt.Sprintf("godal_sp_%d", *p.tx.savepoints)
+	if _, err := p.tx.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	handle := *p
+	handle.tx = &txScope{tx: p.tx.tx, savepoint: name, savepoints: p.tx.savepoints}
+	return &handle, nil
+}
+
+// Commit the transaction, or release the savepoint of a nested scope.
+func (p *Postgres) Commit() error {
+	return p.CommitContext(context.Background())
+}
+
+// CommitContext is Commit running the RELEASE SAVEPOINT of a nested scope
+// with ctx. The COMMIT itself can not be cancelled, database/sql has no
+// context for it.
+func (p *Postgres) CommitContext(ctx context.Context) error {
+	if p.tx == nil {
+		return ErrNotInTx
+	}
+	if p.tx.done {
+		return sql.ErrTxDone
+	}
+	p.tx.done = true
+
+	var err error
+	if p.tx.savepoint == "" {
+		err = p.tx.tx.Commit()
+	} else {
+		_, err = p.tx.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+p.tx.savepoint)
+	}
+	if err != nil {
+		log.Error(err)
+	}
+	return err
+}
+
+// Rollback the transaction, or roll back to the savepoint of a nested scope
+// leaving the outer transaction usable.
+func (p *Postgres) Rollback() error {
+	return p.RollbackContext(context.Background())
+}
+
+// RollbackContext is Rollback running the ROLLBACK TO SAVEPOINT of a nested
+// scope with ctx.
+func (p *Postgres) RollbackContext(ctx context.Context) error {
+	if p.tx == nil {
+		return ErrNotInTx
+	}
+	if p.tx.done {
+		return sql.ErrTxDone
+	}
+	p.tx.done = true
+
+	var err error
+	if p.tx.savepoint == "" {
+		err = p.tx.tx.Rollback()
+	} else {
+		_, err = p.tx.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+p.tx.savepoint)
+		if err == nil {
+			_, err = p.tx.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+p.tx.savepoint)
+		}
+	}
+	if err != nil && err != sql.ErrTxDone {
+		log.Error(err)
+	}
+	return err
+}
+
+func (p *Postgres) WithTx(ctx context.Context, opts *TxOptions, fn func(tx IDatabase) error) error {
+	// A serialization failure aborts the outer transaction too, so nested
+	// scopes never replay and leave the retry to the outermost WithTx.
+	var policy RetryPolicy
+	if opts != nil && p.tx == nil {
+		policy = opts.Retry
+	}
+
+	attempt := 1
+	for {
+		err := p.runTx(ctx, opts, fn)
+		if err == nil || !isRetryableTxError(err) || attempt >= policy.attempts() {
+			return err
+		}
+
+		log.Warnf("Transaction attempt %d failed, retrying: %v", attempt, err)
+		if opts.OnRetry != nil {
+			opts.OnRetry(attempt, err)
+		}
+		if waitErr := policy.wait(ctx, attempt); waitErr != nil {
+			return err
+		}
+		attempt++
+	}
+}
+
+func (p *Postgres) runTx(ctx context.Context, opts *TxOptions, fn func(tx IDatabase) error) error {
+	tx, err := p.Begin(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	// The rollbacks run without ctx: after a cancellation they must still
+	// undo the savepoint, or the outer transaction is left aborted.
+	if err := fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil && rbErr != sql.ErrTxDone {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.CommitContext(ctx)
+}
+
+// isRetryableTxError reports whether err is a serialization failure or a
+// deadlock, after which replaying the whole transaction may succeed.
+func isRetryableTxError(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == sqlStateSerializationFailure || pqErr.Code == sqlStateDeadlockDetected
+}
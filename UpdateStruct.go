@@ -0,0 +1,304 @@
+package godal
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func (p *Postgres) UpdateStruct(tableName string, reqStruct interface{}) (*WriteResult, error) {
+	return p.UpdateStructContext(context.Background(), tableName, reqStruct)
+}
+
+// UpdateStructContext updates the row of reqStruct: the pk fields select the
+// row and every updatable field is written, except the omitempty fields
+// holding their zero value. On a Returning handle the returned row is written
+// back into reqStruct.
+func (p *Postgres) UpdateStructContext(ctx context.Context, tableName string, reqStruct interface{}) (*WriteResult, error) {
+	v, err := updateTarget(reqStruct)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	var listFields []structField
+	for _, field := range structFields(v.Type()) {
+		if field.updatable() {
+			listFields = append(listFields, field)
+		}
+	}
+	return p.updateStruct(ctx, tableName, reqStruct, v, listFields)
+}
+
+func (p *Postgres) UpdateFields(tableName string, reqStruct interface{}, fields ...string) (*WriteResult, error) {
+	return p.UpdateFieldsContext(context.Background(), tableName, reqStruct, fields...)
+}
+
+// UpdateFieldsContext is UpdateStructContext writing only fields, given as
+// column names or Go field names. They are written even when empty.
+func (p *Postgres) UpdateFieldsContext(ctx context.Context, tableName string, reqStruct interface{}, fields ...string) (*WriteResult, error) {
+	v, err := updateTarget(reqStruct)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+
+	listFields, err := lookupFields(v.Type(), fields)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	return p.updateStruct(ctx, tableName, reqStruct, v, listFields)
+}
+
+func (p *Postgres) UpdateChanged(tableName string, reqStruct interface{}, snapshot *Snapshot) (*WriteResult, error) {
+	return p.UpdateChangedContext(context.Background(), tableName, reqStruct, snapshot)
+}
+
+// UpdateChangedContext is UpdateStructContext writing only the columns that
+// changed since snapshot was taken. When nothing changed no statement is run
+// and the result is empty.
+func (p *Postgres) UpdateChangedContext(ctx context.Context, tableName string, reqStruct interface{}, snapshot *Snapshot) (*WriteResult, error) {
+	columns, err := snapshot.Changed(reqStruct)
+	if err != nil {
+		log.Error(err)
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return &WriteResult{}, nil
+	}
+	return p.UpdateFieldsContext(ctx, tableName, reqStruct, columns...)
+}
+
+// The struct updates are new enough to have no interface{} form, so the handle
+// and its Writer share the same *WriteResult methods.
+
+func (w writer) UpdateStruct(tableName string, reqStruct interface{}) (*WriteResult, error) {
+	return w.db.UpdateStruct(tableName, reqStruct)
+}
+
+func (w writer) UpdateStructContext(ctx context.Context, tableName string, reqStruct interface{}) (*WriteResult, error) {
+	return w.db.UpdateStructContext(ctx, tableName, reqStruct)
+}
+
+func (w writer) UpdateFields(tableName string, reqStruct interface{}, fields ...string) (*WriteResult, error) {
+	return w.db.UpdateFields(tableName, reqStruct, fields...)
+}
+
+func (w writer) UpdateFieldsContext(ctx context.Context, tableName string, reqStruct interface{}, fields ...string) (*WriteResult, error) {
+	return w.db.UpdateFieldsContext(ctx, tableName, reqStruct, fields...)
+}
+
+func (w writer) UpdateChanged(tableName string, reqStruct interface{}, snapshot *Snapshot) (*WriteResult, error) {
+	return w.db.UpdateChanged(tableName, reqStruct, snapshot)
+}
+
+func (w writer) UpdateChangedContext(ctx context.Context, tableName string, reqStruct interface{}, snapshot *Snapshot) (*WriteResult, error) {
+	return w.db.UpdateChangedContext(ctx, tableName, reqStruct, snapshot)
+}
+
+func (p *Postgres) updateStruct(ctx context.Context, tableName string, reqStruct interface{}, v reflect.Value, listFields []structField) (*WriteResult, error) {
+	where := Where{}
+	for _, field := range structFields(v.Type()) {
+		if field.pk {
+			where[field.column] = paramValue(fieldByIndex(v, field.index))
+		}
+	}
+	if len(where) == 0 {
+		err := fmt.Errorf("godal: %s has no pk field to select the row of %s", v.Type(), tableName)
+		log.Error(err)
+		return nil, err
+	}
+
+	newValue := make(map[string]interface{}, len(listFields))
+	for _, field := range listFields {
+		if value, ok := fieldParam(v, field); ok {
+			newValue[field.column] = value
+		}
+	}
+	if len(newValue) == 0 {
+		err := fmt.Errorf("godal: no column of %s to update in %s", v.Type(), tableName)
+		log.Error(err)
+		return nil, err
+	}
+
+	return p.update(ctx, tableName, newValue, where, reqStruct)
+}
+
+func updateTarget(reqStruct interface{}) (reflect.Value, error) {
+	v := reflect.Indirect(reflect.ValueOf(reqStruct))
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("godal: update needs a struct or a pointer to a struct, got %T", reqStruct)
+	}
+	return v, nil
+}
+
+// lookupFields returns the updatable fields of t named by names, column names
+// or Go field names, with omitempty turned off.
+func lookupFields(t reflect.Type, names []string) ([]structField, error) {
+	plan := planOf(t)
+	listFields := make([]structField, 0, len(names))
+	for _, name := range names {
+		field := plan.byColumn[name]
+		if field == nil {
+			for i := range plan.fields {
+				if plan.fields[i].name == name {
+					field = &plan.fields[i]
+					break
+				}
+			}
+		}
+		if field == nil {
+			return nil, fmt.Errorf("godal: %s has no field or column %q", t, name)
+		}
+		if !field.updatable() {
+			return nil, fmt.Errorf("godal: field %s of %s can not be updated", field.name, t)
+		}
+
+		f := *field
+		f.omitEmpty = false
+		listFields = append(listFields, f)
+	}
+	return listFields, nil
+}
+
+// Snapshot records the column values of a struct, so UpdateChanged only
+// writes the columns changed since. Nothing takes it automatically: load the
+// row with GetWithSnapshot, or call TakeSnapshot yourself right after loading
+// it, before changing any field.
+//
+//	user, snapshot, err := godal.GetWithSnapshot[*User](ctx, db, "SELECT * FROM users WHERE id = $1", id)
+//	user.Email = "new@example.com"
+//	_, err = db.UpdateChanged("users", user, snapshot) // SET email only
+type Snapshot struct {
+	typ    reflect.Type
+	values map[string]interface{}
+	keys   map[string]interface{}
+}
+
+// GetWithSnapshot is Get returning a snapshot of the row as it was loaded.
+func GetWithSnapshot[T any](ctx context.Context, db IDatabase, sqlQuery string, args ...interface{}) (T, *Snapshot, error) {
+	result, err := Get[T](ctx, db, sqlQuery, args...)
+	if err != nil {
+		return result, nil, err
+	}
+	snapshot, err := TakeSnapshot(result)
+	if err != nil {
+		return result, nil, err
+	}
+	return result, snapshot, nil
+}
+
+// TakeSnapshot records the current values of the pk and updatable fields of reqStruct.
+func TakeSnapshot(reqStruct interface{}) (*Snapshot, error) {
+	v, err := updateTarget(reqStruct)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &Snapshot{typ: v.Type(), values: make(map[string]interface{}), keys: make(map[string]interface{})}
+	for _, field := range snapshotFields(v.Type()) {
+		if snapshot.values[field.column], err = fieldValue(v, field); err != nil {
+			return nil, err
+		}
+	}
+	for _, field := range structFields(v.Type()) {
+		if field.pk {
+			if snapshot.keys[field.column], err = driverValue(paramValue(fieldByIndex(v, field.index))); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return snapshot, nil
+}
+
+// Changed returns the columns of reqStruct whose value differs from the
+// snapshot. A changed pk field is an error, the update would select another row.
+func (s *Snapshot) Changed(reqStruct interface{}) ([]string, error) {
+	v, err := updateTarget(reqStruct)
+	if err != nil {
+		return nil, err
+	}
+	if v.Type() != s.typ {
+		return nil, fmt.Errorf("godal: snapshot of %s compared with %s", s.typ, v.Type())
+	}
+
+	for _, field := range structFields(v.Type()) {
+		if !field.pk {
+			continue
+		}
+		value, err := driverValue(paramValue(fieldByIndex(v, field.index)))
+		if err != nil {
+			return nil, err
+		}
+		if !sameValue(s.keys[field.column], value) {
+			return nil, fmt.Errorf("godal: pk column %q of %s changed since the snapshot", field.column, s.typ)
+		}
+	}
+
+	var columns []string
+	for _, field := range snapshotFields(v.Type()) {
+		value, err := fieldValue(v, field)
+		if err != nil {
+			return nil, err
+		}
+		if !sameValue(s.values[field.column], value) {
+			columns = append(columns, field.column)
+		}
+	}
+	return columns, nil
+}
+
+// fieldValue returns the value of field as it would be sent to the database.
+func fieldValue(v reflect.Value, field structField) (interface{}, error) {
+	value, _ := fieldParam(v, field)
+	return driverValue(value)
+}
+
+// driverValue resolves a driver.Valuer and copies byte slices, so that a
+// snapshot shares no slice or map with the struct, which may be modified in
+// place, e.g. the elements of a pq.StringArray.
+func driverValue(value interface{}) (interface{}, error) {
+	if valuer, ok := value.(driver.Valuer); ok {
+		rv := reflect.ValueOf(value)
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil, nil
+		}
+		var err error
+		if value, err = valuer.Value(); err != nil {
+			return nil, err
+		}
+	}
+	if b, ok := value.([]byte); ok {
+		return append([]byte(nil), b...), nil
+	}
+	return value, nil
+}
+
+func snapshotFields(t reflect.Type) []structField {
+	var listFields []structField
+	for _, field := range structFields(t) {
+		if field.updatable() {
+			field.omitEmpty = false
+			listFields = append(listFields, field)
+		}
+	}
+	return listFields
+}
+
+func sameValue(a, b interface{}) bool {
+	switch a := a.(type) {
+	case time.Time:
+		bt, ok := b.(time.Time)
+		return ok && a.Equal(bt)
+	case []byte:
+		bb, ok := b.([]byte)
+		return ok && bytes.Equal(a, bb)
+	}
+	return reflect.DeepEqual(a, b)
+}
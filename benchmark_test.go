@@ -0,0 +1,80 @@
+package godal
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type benchReport struct {
+	ID        int64     `db:"id,pk"`
+	Name      string    `db:"name"`
+	Email     string    `db:"email"`
+	Amount    float64   `db:"amount"`
+	Status    string    `db:"status"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+var (
+	benchColumns = []string{"id", "name", "email", "amount", "status", "created_at"}
+	benchRow     = []interface{}{int64(1), []byte("Hung Son"), []byte("son@gmail.com"), []byte("125.50"), []byte("active"), time.Now()}
+)
+
+const benchRows = 1000
+
+// BenchmarkScanStructFieldByName scans the way the mapper did before the
+// plan cache: walk the tags per query and FieldByName per column and row.
+func BenchmarkScanStructFieldByName(b *testing.B) {
+	attrType := reflect.TypeOf(benchReport{})
+	for n := 0; n < b.N; n++ {
+		mapAttr := make(map[string]string)
+		for k := 0; k < attrType.NumField(); k++ {
+			dbFieldName, _ := parseDBTag(attrType.Field(k).Tag.Get("db"))
+			mapAttr[dbFieldName] = attrType.Field(k).Name
+		}
+
+		for r := 0; r < benchRows; r++ {
+			newStruct := reflect.New(attrType).Elem()
+			for i, col := range benchRow {
+				if err := convertAssign(newStruct.FieldByName(mapAttr[benchColumns[i]]), col); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkScanStructPlan(b *testing.B) {
+	attrType := reflect.TypeOf(benchReport{})
+	for n := 0; n < b.N; n++ {
+		fields := planOf(attrType).columnFields(benchColumns)
+
+		for r := 0; r < benchRows; r++ {
+			newStruct := reflect.New(attrType).Elem()
+			if err := scanStructRow(newStruct, fields, benchColumns, benchRow); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkStructFieldsUncached(b *testing.B) {
+	attrType := reflect.TypeOf(benchReport{})
+	for n := 0; n < b.N; n++ {
+		appendStructFields(nil, attrType, nil, "", "", nil)
+	}
+}
+
+func BenchmarkStructFieldsCached(b *testing.B) {
+	attrType := reflect.TypeOf(benchReport{})
+	for n := 0; n < b.N; n++ {
+		structFields(attrType)
+	}
+}
+
+func BenchmarkConvertStructToParams(b *testing.B) {
+	report := benchReport{ID: 1, Name: "Hung Son", Email: "son@gmail.com", Amount: 125.5, Status: "active", CreatedAt: time.Now()}
+	for n := 0; n < b.N; n++ {
+		convertStructToParams(&report)
+	}
+}
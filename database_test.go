@@ -1,10 +1,18 @@
 package godal
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/lib/pq"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -31,7 +39,7 @@ func TestMain(m *testing.M) {
 }
 
 func setup() {
-	pg = Postgres{
+	pg = &Postgres{
 		Host:        "127.0.0.1",
 		Port:        "5432",
 		Dbname:      "dbtest",
@@ -41,7 +49,9 @@ func setup() {
 		MaxOpenConn: 2,
 		SSLMode:     "disable",
 	}
-	pg.Connect()
+	if err := pg.Connect(); err != nil {
+		log.Warnln("Database is not available, tests needing it are skipped: ", err)
+	}
 }
 
 func TestCreate(t *testing.T) {
@@ -280,3 +290,1063 @@ func TestCreateOrUpdateStruct(t *testing.T) {
 	}
 	log.Infoln(rs)
 }
+
+func TestNotConnected(t *testing.T) {
+	other := &Postgres{Host: "127.0.0.1", Dbname: "other"}
+	if other.DB() != nil {
+		t.Fatal("new instance must not share a connection pool")
+	}
+
+	_, err := other.Execute("SELECT 1", nil)
+	if err != ErrNotConnected {
+		t.Fatalf("expected ErrNotConnected, got %v", err)
+	}
+}
+
+func TestExecuteContextCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := pg.ExecuteContext(ctx, "SELECT pg_sleep(5)", nil)
+	if err == nil {
+		t.Fatal("expected the statement to be cancelled")
+	}
+	if err == ErrNotConnected {
+		t.Skip("database is not available")
+	}
+	if ctx.Err() == nil {
+		t.Fatalf("statement failed before the deadline: %v", err)
+	}
+}
+
+func TestConnectError(t *testing.T) {
+	other := &Postgres{
+		Host:    "127.0.0.1",
+		Port:    "1",
+		Dbname:  "dbtest",
+		SSLMode: "disable",
+		ConnectRetry: RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Millisecond,
+		},
+	}
+
+	err := other.Connect()
+	connErr, ok := err.(*ConnectError)
+	if !ok {
+		t.Fatalf("expected *ConnectError, got %v", err)
+	}
+	if connErr.Op != "ping" || connErr.Attempts != 3 {
+		t.Fatalf("unexpected error %+v", connErr)
+	}
+	if other.DB() != nil {
+		t.Fatal("failed connect must not keep a pool")
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond, Multiplier: 2}
+	expected := []time.Duration{10, 20, 40, 50, 50}
+	for i, want := range expected {
+		if got := policy.backoff(i + 1); got != want*time.Millisecond {
+			t.Errorf("attempt %d: expected %s, got %s", i+1, want*time.Millisecond, got)
+		}
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	if err := (&Postgres{}).Commit(); err != ErrNotInTx {
+		t.Fatalf("expected ErrNotInTx, got %v", err)
+	}
+	if err := (&Postgres{}).RollbackContext(context.Background()); err != ErrNotInTx {
+		t.Fatalf("expected ErrNotInTx, got %v", err)
+	}
+	done := &Postgres{tx: &txScope{savepoint: "godal_sp_1", done: true}}
+	if err := done.CommitContext(context.Background()); err != sql.ErrTxDone {
+		t.Fatalf("expected ErrTxDone, got %v", err)
+	}
+
+	errAbort := errors.New("abort")
+	err := pg.WithTx(context.Background(), &TxOptions{Isolation: sql.LevelSerializable}, func(tx IDatabase) error {
+		_, err := tx.Execute("CREATE TEMP TABLE godal_tx_test (id int)", nil)
+		if err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if err == ErrNotConnected {
+		t.Skip("database is not available")
+	}
+	if !errors.Is(err, errAbort) {
+		t.Fatalf("expected the function error, got %v", err)
+	}
+
+	_, err = pg.Execute("SELECT * FROM godal_tx_test", nil)
+	if err == nil {
+		t.Fatal("table created inside a rolled back transaction must not exist")
+	}
+}
+
+func TestIsRetryableTxError(t *testing.T) {
+	cases := map[error]bool{
+		&pq.Error{Code: "40001"}:                            true,
+		fmt.Errorf("wrapped: %w", &pq.Error{Code: "40P01"}): true,
+		&pq.Error{Code: "23505"}:                            false,
+		errors.New("40001"):                                 false,
+	}
+	for err, want := range cases {
+		if got := isRetryableTxError(err); got != want {
+			t.Errorf("%v: expected %v, got %v", err, want, got)
+		}
+	}
+}
+
+func TestNestedWithTx(t *testing.T) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	var count []map[string]interface{}
+	err := pg.WithTx(ctx, nil, func(tx IDatabase) error {
+		if _, err := tx.Execute("CREATE TEMP TABLE godal_sp_test (id int) ON COMMIT DROP", nil); err != nil {
+			return err
+		}
+
+		err := tx.WithTx(ctx, nil, func(inner IDatabase) error {
+			if _, err := inner.Execute("INSERT INTO godal_sp_test VALUES (1)", nil); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if err != errAbort {
+			return err
+		}
+
+		if _, err := tx.Execute("INSERT INTO godal_sp_test VALUES (2)", nil); err != nil {
+			return err
+		}
+
+		count, err = tx.ExecuteSelectToMap("SELECT count(*) AS n FROM godal_sp_test", nil)
+		return err
+	})
+	if err == ErrNotConnected {
+		t.Skip("database is not available")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count[0]["n"] != int64(1) {
+		t.Fatalf("expected only the outer row, got %v", count[0]["n"])
+	}
+}
+
+func TestBuildWhere(t *testing.T) {
+	cases := []struct {
+		where  interface{}
+		sql    string
+		params int
+	}{
+		{map[string]interface{}{"id": 1, "deleted_at": nil}, `"deleted_at" IS NULL AND "id" = $1`, 1},
+		{In("id", []int{1, 2, 3}), `"id" IN ($1, $2, $3)`, 3},
+		{
+			And(Gt("age", 18), Or(ILike("name", "son%"), Between("score", 1, 5)), Not(IsNull("email"))),
+			`"age" > $1 AND ("name" ILIKE $2 OR "score" BETWEEN $3 AND $4) AND (NOT ("email" IS NULL))`, 4,
+		},
+		{NotIn("id"), "TRUE", 0},
+		{
+			And(Raw("a = ? OR b = ?", 1, 2), Eq("c", 3)),
+			`(a = $1 OR b = $2) AND "c" = $3`, 3,
+		},
+		{
+			Or(Where{"a": 1, "b": 2}, Where{"c": 3}),
+			`("a" = $1 AND "b" = $2) OR "c" = $3`, 3,
+		},
+		{And(Raw("a = ? OR b = ?", 1, 2)), `a = $1 OR b = $2`, 2},
+		{Raw("data ?? ? AND tags ??| ? AND tags ??& ?", "k", "a", "b"), `data ? $1 AND tags ?| $2 AND tags ?& $3`, 3},
+		{Raw("data ?? 'k'"), `data ? 'k'`, 0},
+	}
+	for _, c := range cases {
+		args := &queryArgs{}
+		got, err := buildWhere(c.where, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != c.sql || len(args.values) != c.params {
+			t.Errorf("expected %q with %d params, got %q with %v", c.sql, c.params, got, args.values)
+		}
+	}
+
+	for _, raw := range []Condition{Raw("data ? 'k'"), Raw("a = ?", 1, 2)} {
+		if _, err := buildWhere(raw, &queryArgs{}); err == nil || !strings.Contains(err.Error(), "placeholders") {
+			t.Errorf("expected a placeholder count error, got %v", err)
+		}
+	}
+	if _, err := buildWhere(map[string]interface{}{}, &queryArgs{}); err != ErrEmptyCondition {
+		t.Errorf("expected ErrEmptyCondition, got %v", err)
+	}
+}
+
+func TestSelectBuilder(t *testing.T) {
+	db := &Postgres{}
+	active := db.From("orders").Select("user_id").Where(Eq("status", "active"))
+	sqlQuery, params, err := db.From("users u").
+		Select("u.id").
+		SelectRaw("count(o.id) AS total").
+		Distinct().
+		LeftJoin("orders o", "o.user_id = u.id").
+		Where(Gte("u.age", 18), In("u.id", active)).
+		GroupBy("u.id").
+		Having(Raw("count(o.id) > ?", 2)).
+		OrderBy("total DESC").
+		Limit(10).
+		Offset(20).
+		ToSQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `SELECT DISTINCT "u"."id", count(o.id) AS total FROM "users" AS "u" LEFT JOIN "orders" AS "o" ON o.user_id = u.id` +
+		` WHERE "u"."age" >= $1 AND "u"."id" IN (SELECT "user_id" FROM "orders" WHERE "status" = $2)` +
+		` GROUP BY "u"."id" HAVING count(o.id) > $3 ORDER BY "total" DESC LIMIT 10 OFFSET 20`
+	if sqlQuery != expected {
+		t.Errorf("unexpected sql:\n%s\n%s", sqlQuery, expected)
+	}
+	if len(params) != 3 || params[1] != "active" {
+		t.Errorf("unexpected params %v", params)
+	}
+
+	strict := &Postgres{StrictIdentifiers: true, AllowedIdentifiers: []string{"users", "id", "name", "u.id", "created_at"}}
+	sqlQuery, _, err = strict.From("users AS u").
+		Select("u.id", "name AS n").
+		SelectRaw("count(*) AS total").
+		GroupBy("u.id", "name").
+		OrderByRaw("lower(name)").
+		OrderBy("created_at DESC").
+		ToSQL()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `SELECT "u"."id", "name" AS "n", count(*) AS total FROM "users" AS "u" GROUP BY "u"."id", "name" ORDER BY lower(name), "created_at" DESC`
+	if sqlQuery != expected {
+		t.Errorf("unexpected strict sql:\n%s\n%s", sqlQuery, expected)
+	}
+
+	sqlQuery, _, err = db.From("users u").
+		JoinRaw("LEFT JOIN LATERAL (SELECT 1) AS l ON true").
+		Join("orders o", "o.user_id = u.id").
+		ToSQL()
+	expected = `SELECT * FROM "users" AS "u" LEFT JOIN LATERAL (SELECT 1) AS l ON true JOIN "orders" AS "o" ON o.user_id = u.id`
+	if err != nil || sqlQuery != expected {
+		t.Errorf("unexpected join sql (%v):\n%s\n%s", err, sqlQuery, expected)
+	}
+
+	for _, pg := range []*Postgres{db, strict} {
+		rejected := []*SelectBuilder{
+			pg.From("users; DELETE FROM users --"),
+			pg.From("users").Select("count(*)"),
+			pg.From("users").GroupBy("lower(name)"),
+			pg.From("users").OrderBy("name; DROP TABLE users"),
+			pg.From("users").OrderBy("(SELECT password FROM admins LIMIT 1)"),
+			pg.From("users").Join("users u2 ON true; DROP TABLE users --", "true"),
+		}
+		for _, b := range rejected {
+			if sqlQuery, _, err := b.ToSQL(); err == nil {
+				t.Errorf("expected strict=%v to reject %s", pg.StrictIdentifiers, sqlQuery)
+			}
+		}
+	}
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	idents := identQuoter{}
+	cases := map[string]string{
+		"users":               `"users"`,
+		"public.users":        `"public"."users"`,
+		"order":               `"order"`,
+		`"Mixed.Case"`:        `"Mixed.Case"`,
+		`id"); DROP TABLE x;`: `"id""); DROP TABLE x;"`,
+	}
+	for name, want := range cases {
+		got, err := idents.quote(name)
+		if err != nil || got != want {
+			t.Errorf("%s: expected %s, got %s (%v)", name, want, got, err)
+		}
+	}
+
+	strict := (&Postgres{StrictIdentifiers: true, AllowedIdentifiers: []string{"public", "users", "id"}}).identQuoter()
+	if _, err := strict.quote("public.users"); err != nil {
+		t.Error(err)
+	}
+	if _, err := strict.quote("password"); err == nil {
+		t.Error("expected identifier outside the allowlist to be rejected")
+	}
+}
+
+func TestSelectGeneric(t *testing.T) {
+	type Users struct {
+		ID   string `db:"id"`
+		Name string `db:"name"`
+	}
+
+	if _, err := Select[int](context.Background(), pg, "SELECT 1"); err == nil {
+		t.Error("expected an error for a non struct type")
+	}
+
+	users, err := Select[Users](context.Background(), pg, "SELECT id::text, name FROM users WHERE id::text <> $1", "0")
+	if err == ErrNotConnected {
+		t.Skip("database is not available")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.Infoln(users)
+
+	_, err = Get[*Users](context.Background(), pg, "SELECT id::text, name FROM users WHERE false")
+	if err != ErrNotFound {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	type Row struct {
+		N int64 `db:"n"`
+	}
+	first, err := Get[Row](context.Background(), pg, "SELECT g AS n FROM generate_series(1, 1000000) g")
+	if err != nil || first.N != 1 {
+		t.Fatalf("expected the first row, got %v (%v)", first, err)
+	}
+}
+
+func TestConvertAssign(t *testing.T) {
+	type Meta struct {
+		Tags []string `json:"tags"`
+	}
+	var row struct {
+		Age     int
+		Small   int8
+		Name    string
+		Price   float64
+		Created *time.Time
+		Deleted *time.Time
+		Raw     json.RawMessage
+		Meta    Meta
+		Note    sql.NullString
+	}
+	now := time.Now()
+	v := reflect.ValueOf(&row).Elem()
+
+	assign := map[string]interface{}{
+		"Age":     int64(42),
+		"Name":    []byte("son"),
+		"Price":   []byte("12.50"),
+		"Created": now,
+		"Deleted": nil,
+		"Raw":     []byte(`{"a":1}`),
+		"Meta":    []byte(`{"tags":["x"]}`),
+		"Note":    "hello",
+	}
+	for field, src := range assign {
+		if err := convertAssign(v.FieldByName(field), src); err != nil {
+			t.Fatalf("%s: %v", field, err)
+		}
+	}
+	if row.Age != 42 || row.Name != "son" || row.Price != 12.5 || !row.Created.Equal(now) || row.Deleted != nil ||
+		string(row.Raw) != `{"a":1}` || row.Meta.Tags[0] != "x" || row.Note.String != "hello" {
+		t.Errorf("unexpected result %+v", row)
+	}
+
+	if err := convertAssign(v.FieldByName("Small"), int64(300)); err == nil {
+		t.Error("expected an overflow error")
+	}
+}
+
+func TestStructFieldsNested(t *testing.T) {
+	type BaseModel struct {
+		ID        int64      `db:"id"`
+		CreatedAt *time.Time `db:"created_at"`
+	}
+	type Address struct {
+		City   string `db:"city"`
+		Street string `db:"street"`
+	}
+	type Customer struct {
+		BaseModel
+		Name     *string  `db:"name"`
+		Address  Address  `db:",prefix=addr_"`
+		Shipping *Address `db:"shipping"`
+		Tags     []string `db:"tags"`
+	}
+
+	name := "son"
+	customer := Customer{BaseModel: BaseModel{ID: 7}, Name: &name, Address: Address{City: "HCM"}, Tags: []string{"a"}}
+	arrValues, listFields, _ := convertStructToParams(&customer)
+	listColumns := columnsOf(listFields)
+
+	expected := []string{"id", "created_at", "name", "addr_city", "addr_street", "shipping.city", "shipping.street", "tags"}
+	if !reflect.DeepEqual(listColumns, expected) {
+		t.Fatalf("expected columns %v, got %v", expected, listColumns)
+	}
+	if arrValues[0] != int64(7) || arrValues[1] != nil || arrValues[2] != "son" || arrValues[3] != "HCM" ||
+		arrValues[5] != nil || string(arrValues[7].([]byte)) != `["a"]` {
+		t.Errorf("unexpected values %v", arrValues)
+	}
+
+	sqlStatement, _, err := insertStructSQL(identQuoter{}, "sales.customers", &customer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedSQL := `INSERT INTO "sales"."customers"("id", "created_at", "name", "addr_city", "addr_street", "shipping.city", "shipping.street", "tags")`
+	if !strings.Contains(sqlStatement, expectedSQL) {
+		t.Errorf("expected %s in %s", expectedSQL, sqlStatement)
+	}
+
+	conflict, err := OnConflict{Columns: []string{"id"}}.toSQL(identQuoter{}, `"customers"`, listColumns, nil)
+	if err != nil || !strings.Contains(conflict, `"shipping.city" = EXCLUDED."shipping.city"`) {
+		t.Errorf("expected the nested column quoted as one identifier, got %s (%v)", conflict, err)
+	}
+
+	strict := identQuoter{strict: true, allowed: map[string]bool{"customers": true, "shipping.city": true}}
+	if _, err := strict.quoteColumns([]string{"shipping.city"}); err != nil {
+		t.Errorf("expected the allowed nested column to pass, got %v", err)
+	}
+	if _, err := strict.quoteColumns([]string{"shipping.street"}); err == nil {
+		t.Error("expected the nested column outside the allowlist to be rejected")
+	}
+}
+
+func TestStructFieldsRecursive(t *testing.T) {
+	type Node struct {
+		ID     int64 `db:"id"`
+		Parent *Node `db:"parent"`
+	}
+	type Category struct {
+		ID   int64 `db:"id"`
+		Root struct {
+			Name string    `db:"name"`
+			Top  *Category `db:"top"`
+		} `db:"root"`
+	}
+
+	fields := structFields(reflect.TypeOf(Node{}))
+	if columns := columnsOf(fields); !reflect.DeepEqual(columns, []string{"id", "parent"}) || !fields[1].json {
+		t.Fatalf("expected parent as a JSON column, got %v", fields)
+	}
+	if columns := columnsOf(structFields(reflect.TypeOf(Category{}))); !reflect.DeepEqual(columns, []string{"id", "root.name", "root.top"}) {
+		t.Fatalf("unexpected columns %v", columns)
+	}
+
+	arrValues, _, _ := convertStructToParams(&Node{ID: 2, Parent: &Node{ID: 1}})
+	if string(arrValues[1].([]byte)) != `{"ID":1,"Parent":null}` {
+		t.Errorf("unexpected parent value %s", arrValues[1])
+	}
+}
+
+func TestStructTagOptions(t *testing.T) {
+	type Account struct {
+		ID       int64             `db:"id,pk,no_insert"`
+		Tenant   string            `db:"tenant_id,pk"`
+		Email    string            `db:"email,omitempty"`
+		Created  time.Time         `db:"created_at,no_update"`
+		Version  int               `db:"version,readonly"`
+		Settings map[string]string `db:"settings,json"`
+		Secret   string            `db:"-"`
+	}
+
+	account := Account{Tenant: "t1", Created: time.Now()}
+	arrValues, listFields, strValues := convertStructToParams(account)
+	if columns := columnsOf(listFields); !reflect.DeepEqual(columns, []string{"tenant_id", "created_at", "settings"}) {
+		t.Fatalf("unexpected insert columns %v", columns)
+	}
+	if strValues != "$1, $2, $3" || arrValues[2] != nil {
+		t.Errorf("unexpected values %v %s", arrValues, strValues)
+	}
+
+	var updatable []string
+	for _, field := range structFields(reflect.TypeOf(account)) {
+		if field.updatable() {
+			updatable = append(updatable, field.column)
+		}
+	}
+	if !reflect.DeepEqual(updatable, []string{"email", "settings"}) {
+		t.Errorf("unexpected update columns %v", updatable)
+	}
+}
+
+func TestForEachStopsEarly(t *testing.T) {
+	errStop := errors.New("stop")
+	seen := 0
+	err := pg.ForEachMapContext(context.Background(), "SELECT g AS n FROM generate_series(1, 1000000) g", nil, func(row map[string]interface{}) error {
+		seen++
+		if seen == 10 {
+			return errStop
+		}
+		return nil
+	})
+	if err == ErrNotConnected {
+		t.Skip("database is not available")
+	}
+	if err != errStop || seen != 10 {
+		t.Fatalf("expected to stop after 10 rows, got %d rows and %v", seen, err)
+	}
+
+	type Row struct {
+		N int `db:"n"`
+	}
+	rows, err := pg.ExecuteSelectRows("SELECT g AS n FROM generate_series(1, 3) g", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	sum := 0
+	for rows.Next() {
+		row := Row{}
+		if err := rows.Scan(&row); err != nil {
+			t.Fatal(err)
+		}
+		sum += row.N
+	}
+	if rows.Err() != nil || sum != 6 {
+		t.Fatalf("expected sum 6, got %d (%v)", sum, rows.Err())
+	}
+}
+
+func TestForEachStopsEarlyInTx(t *testing.T) {
+	ctx := context.Background()
+	errStop := errors.New("stop")
+	var count []map[string]interface{}
+	err := pg.WithTx(ctx, nil, func(tx IDatabase) error {
+		err := tx.ForEachMapContext(ctx, "SELECT g AS n FROM generate_series(1, 100000) g", nil, func(row map[string]interface{}) error {
+			return errStop
+		})
+		if err != errStop {
+			return fmt.Errorf("expected errStop, got %v", err)
+		}
+
+		// The transaction must not be aborted by the early stop.
+		count, err = tx.ExecuteSelectToMapContext(ctx, "SELECT 1 AS n", nil)
+		return err
+	})
+	if errors.Is(err, ErrNotConnected) {
+		t.Skip("database is not available")
+	}
+	if err != nil || len(count) != 1 {
+		t.Fatalf("expected the transaction to stay usable, got %v (%v)", count, err)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	ctx := context.Background()
+	if _, err := (&Cursor{}).FetchMap(ctx, -1); err == nil {
+		t.Error("expected an error for a negative batch size")
+	}
+	if _, err := (&Cursor{}).FetchStruct(ctx, -1, &User{}); err == nil {
+		t.Error("expected an error for a negative batch size")
+	}
+
+	cursor, err := pg.DeclareCursor(ctx, "SELECT g AS n FROM generate_series(1, $1::int) g", []interface{}{25})
+	if err == ErrNotConnected {
+		t.Skip("database is not available")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cursor.Close()
+
+	type Row struct {
+		N int64 `db:"n"`
+	}
+	first, err := cursor.FetchStruct(ctx, 10, Row{})
+	if err != nil || len(first) != 10 || first[9].(*Row).N != 10 {
+		t.Fatalf("unexpected first batch %v (%v)", first, err)
+	}
+
+	rest := 0
+	err = cursor.ForEachMap(ctx, 10, func(row map[string]interface{}) error {
+		rest++
+		return nil
+	})
+	if err != nil || rest != 15 {
+		t.Fatalf("expected the 15 remaining rows, got %d (%v)", rest, err)
+	}
+
+	if err := cursor.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cursor.FetchMap(ctx, 1); err != ErrCursorClosed {
+		t.Fatalf("expected ErrCursorClosed, got %v", err)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	builder := (&Postgres{}).From("users").Where(Eq("active", true))
+	orderBy := []string{"created_at DESC", "id"}
+
+	var lastSQL string
+	fetch := func(q *SelectBuilder) ([]interface{}, error) {
+		lastSQL, _, _ = q.ToSQL()
+		items := make([]interface{}, 0)
+		for i := 1; i <= 3; i++ {
+			items = append(items, map[string]interface{}{"id": int64(i), "created_at": []byte(fmt.Sprintf("2020-01-0%d", i))})
+		}
+		return items, nil
+	}
+
+	page, items, err := builder.paginate(context.Background(), PageRequest{OrderBy: orderBy, Size: 2}, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || page.Next == "" || page.Prev != "" {
+		t.Fatalf("unexpected first page %+v", page)
+	}
+	if lastSQL != `SELECT * FROM "users" WHERE "active" = $1 ORDER BY "created_at" DESC, "id" ASC LIMIT 3` {
+		t.Errorf("unexpected first page sql %s", lastSQL)
+	}
+
+	page, items, err = builder.paginate(context.Background(), PageRequest{OrderBy: orderBy, Size: 2, Cursor: page.Next}, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `SELECT * FROM "users" WHERE "active" = $1 AND ("created_at" < $2 OR ("created_at" = $3 AND "id" > $4))` +
+		` ORDER BY "created_at" DESC, "id" ASC LIMIT 3`
+	if lastSQL != expected || page.Prev == "" {
+		t.Errorf("unexpected next page sql %s", lastSQL)
+	}
+
+	_, items, err = builder.paginate(context.Background(), PageRequest{OrderBy: orderBy, Size: 2, Cursor: page.Prev}, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(lastSQL, `"created_at" > $2`) || !strings.Contains(lastSQL, `ORDER BY "created_at" ASC, "id" DESC`) {
+		t.Errorf("unexpected previous page sql %s", lastSQL)
+	}
+	if items[0].(map[string]interface{})["id"] != int64(2) {
+		t.Errorf("previous page must be returned in sort order, got %v", items)
+	}
+
+	if _, _, err = builder.paginate(context.Background(), PageRequest{OrderBy: []string{"id"}, Size: 2, Cursor: page.Next}, fetch); !errors.Is(err, ErrInvalidPageCursor) {
+		t.Errorf("expected ErrInvalidPageCursor, got %v", err)
+	}
+}
+
+func TestCopySource(t *testing.T) {
+	source, columns, err := newCopySource([]map[string]interface{}{
+		{"name": "a", "age": 1},
+		{"name": "b", "tags": []string{"x"}},
+	}, nil)
+	if err != nil || !reflect.DeepEqual(columns, []string{"age", "name", "tags"}) {
+		t.Fatalf("unexpected columns %v (%v)", columns, err)
+	}
+	var rows [][]interface{}
+	for source.Next() {
+		values, _ := source.Values()
+		rows = append(rows, values)
+	}
+	if len(rows) != 2 || rows[1][0] != nil || string(rows[1][2].([]byte)) != `["x"]` {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+
+	type User struct {
+		ID   int64  `db:"id,pk,readonly"`
+		Name string `db:"name,omitempty"`
+	}
+	source, columns, err = newCopySource([]*User{{ID: 1, Name: ""}}, nil)
+	if err != nil || !reflect.DeepEqual(columns, []string{"name"}) {
+		t.Fatalf("unexpected struct columns %v (%v)", columns, err)
+	}
+	source.Next()
+	if values, _ := source.Values(); values[0] != "" {
+		t.Fatalf("omitempty must not drop COPY values, got %v", values)
+	}
+
+	if _, _, err := newCopySource([]User{}, []string{"missing"}); err == nil {
+		t.Fatal("expected an error for an unknown column")
+	}
+	if _, _, err := newCopySource(42, nil); err == nil {
+		t.Fatal("expected an error for unsupported rows")
+	}
+
+	copyErr := copyError(0, &pq.Error{Message: "invalid input", Where: "COPY users, line 3, column age: \"x\""})
+	var ce *CopyError
+	if !errors.As(copyErr, &ce) || ce.Row != 2 {
+		t.Fatalf("expected row 2, got %v", copyErr)
+	}
+}
+
+func TestCopyStatement(t *testing.T) {
+	idents := (&Postgres{}).identQuoter()
+	copySQL, err := copyStatement(idents, "public.users", []string{"name"})
+	if err != nil || copySQL != `COPY "public"."users" ("name") FROM STDIN` {
+		t.Errorf("unexpected copy sql %q (%v)", copySQL, err)
+	}
+	if _, err = copyStatement(idents, "db.public.users", []string{"name"}); err == nil {
+		t.Error("expected an error for a three-part table name")
+	}
+	if _, err = (&Postgres{}).CopyFrom(context.Background(), "db.public.users", []string{"name"}, []map[string]interface{}{{"name": "a"}}); err == nil {
+		t.Error("expected CopyFrom to reject a three-part table name")
+	}
+
+	strict := (&Postgres{StrictIdentifiers: true, AllowedIdentifiers: []string{"users", "name"}}).identQuoter()
+	if _, err = copyStatement(strict, "users", []string{"name"}); err != nil {
+		t.Errorf("unexpected strict error: %v", err)
+	}
+	if _, err = copyStatement(strict, "users", []string{"password"}); err == nil {
+		t.Error("expected strict mode to reject a column outside the allowlist")
+	}
+	if _, err = copyStatement(strict, "accounts", []string{"name"}); err == nil {
+		t.Error("expected strict mode to reject a table outside the allowlist")
+	}
+}
+
+func TestCopyFrom(t *testing.T) {
+	ctx := context.Background()
+	var n int64
+	err := pg.WithTx(ctx, nil, func(tx IDatabase) error {
+		// The temp table only lives on the connection of the transaction.
+		if _, err := tx.Execute("CREATE TEMP TABLE godal_copy (id int, name text) ON COMMIT DROP", nil); err != nil {
+			return err
+		}
+		var err error
+		n, err = tx.CopyFrom(ctx, "godal_copy", nil, []map[string]interface{}{
+			{"id": 1, "name": "a"},
+			{"id": 2, "name": "b"},
+		})
+		return err
+	})
+	if errors.Is(err, ErrNotConnected) {
+		t.Skip("database is not available")
+	}
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 rows, got %d (%v)", n, err)
+	}
+}
+
+func TestBatchChunks(t *testing.T) {
+	if size := (BatchOptions{}).chunkSize(3); size != 21845 {
+		t.Fatalf("expected 21845 rows per chunk, got %d", size)
+	}
+	if size := (BatchOptions{ChunkSize: 100}).chunkSize(3); size != 100 {
+		t.Fatalf("expected the configured chunk size, got %d", size)
+	}
+	if size := (BatchOptions{ChunkSize: 100000}).chunkSize(1); size != maxParams {
+		t.Fatalf("expected the chunk size to be capped at %d, got %d", maxParams, size)
+	}
+
+	_, values := convertListMapToParams([]map[string]interface{}{{"id": 1}, {"id": 2}}, []string{"id"}, false)
+	if values != "($1), ($2)" {
+		t.Fatalf("unexpected values %q", values)
+	}
+
+	var chunks []int
+	rs, err := (&Postgres{}).execChunks(context.Background(), make([]map[string]interface{}, 5), 1, BatchOptions{ChunkSize: 2, CommitPerChunk: true},
+		func(chunk []map[string]interface{}) (string, []interface{}) {
+			chunks = append(chunks, len(chunk))
+			return "", nil
+		})
+	if err != ErrNotConnected || rs == nil || !reflect.DeepEqual(chunks, []int{2}) {
+		t.Fatalf("expected to stop at the first chunk, got %v %v (%v)", chunks, rs, err)
+	}
+
+	ctx := context.Background()
+	err = pg.WithTx(ctx, nil, func(tx IDatabase) error {
+		if _, err := tx.Execute("CREATE TEMP TABLE godal_batch (id int) ON COMMIT DROP", nil); err != nil {
+			return err
+		}
+		rs, err := tx.CreateBatchWithOptionsContext(ctx, "godal_batch", []map[string]interface{}{
+			{"id": 1}, {"id": 2}, {"id": 3},
+		}, BatchOptions{ChunkSize: 2})
+		if err != nil {
+			return err
+		}
+		if n, _ := rs.(sql.Result).RowsAffected(); n != 3 {
+			t.Errorf("expected 3 affected rows, got %d", n)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNotConnected) {
+		t.Skip("database is not available")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBatchColumns(t *testing.T) {
+	rows := []map[string]interface{}{
+		{"id": 1, "name": "a", "age": 30},
+		{"id": 2, "email": "b@example.com"},
+	}
+
+	columns, err := batchColumns(rows, BatchOptions{})
+	if err != nil || !reflect.DeepEqual(columns, []string{"age", "email", "id", "name"}) {
+		t.Fatalf("expected the union of the keys, got %v (%v)", columns, err)
+	}
+
+	params, values := convertListMapToParams(rows, columns, false)
+	if values != "($1, $2, $3, $4), ($5, $6, $7, $8)" || len(params) != 8 || params[4] != nil {
+		t.Fatalf("unexpected NULL values %q %v", values, params)
+	}
+	params, values = convertListMapToParams(rows, columns, true)
+	if values != "($1, DEFAULT, $2, $3), (DEFAULT, $4, $5, DEFAULT)" || len(params) != 5 {
+		t.Fatalf("unexpected DEFAULT values %q %v", values, params)
+	}
+
+	if _, err := batchColumns(rows, BatchOptions{Columns: []string{"id", "name", "age"}}); err == nil || !strings.Contains(err.Error(), `"email"`) {
+		t.Fatalf("expected an error for the unknown key, got %v", err)
+	}
+	columns, err = batchColumns(rows, BatchOptions{Columns: []string{"id", "name", "age", "email"}})
+	if err != nil || !reflect.DeepEqual(columns, []string{"id", "name", "age", "email"}) {
+		t.Fatalf("expected the explicit column list, got %v (%v)", columns, err)
+	}
+}
+
+func TestOnConflict(t *testing.T) {
+	columns := []string{"id", "name", "tenant_id"}
+	cases := []struct {
+		conflict OnConflict
+		sql      string
+	}{
+		{conflictColumns("tenant_id, id"), `ON CONFLICT ("tenant_id", "id") DO UPDATE SET "name" = EXCLUDED."name"`},
+		{OnConflict{Constraint: "users_pkey", Update: []string{"name"}}, `ON CONFLICT ON CONSTRAINT "users_pkey" DO UPDATE SET "name" = EXCLUDED."name"`},
+		{OnConflict{Columns: []string{"name"}, Where: "deleted_at IS NULL"}, `ON CONFLICT ("name") WHERE deleted_at IS NULL DO UPDATE SET "id" = EXCLUDED."id", "tenant_id" = EXCLUDED."tenant_id"`},
+		{OnConflict{DoNothing: true}, `ON CONFLICT DO NOTHING`},
+		{OnConflict{Columns: columns}, `ON CONFLICT ("id", "name", "tenant_id") DO NOTHING`},
+	}
+	for _, c := range cases {
+		got, err := c.conflict.toSQL(identQuoter{}, `"users"`, columns, nil)
+		if err != nil || got != c.sql {
+			t.Errorf("expected %s, got %s (%v)", c.sql, got, err)
+		}
+	}
+
+	invalid := []OnConflict{
+		{Columns: []string{"id"}, Constraint: "users_pkey"},
+		{Where: "deleted_at IS NULL"},
+		{Columns: []string{"id"}, DoNothing: true, Update: []string{"name"}},
+		{Columns: []string{"id"}, Update: []string{"email"}},
+		{Columns: []string{"id"}, Merge: map[string]MergeStrategy{"id": MergeAdd}},
+		{Columns: []string{"id"}, Merge: map[string]MergeStrategy{"name": MergeStrategy(42)}},
+		{},
+	}
+	for _, conflict := range invalid {
+		if got, err := conflict.toSQL(identQuoter{}, `"users"`, columns, nil); err == nil {
+			t.Errorf("expected an error for %+v, got %s", conflict, got)
+		}
+	}
+}
+
+func TestOnConflictMerge(t *testing.T) {
+	conflict := OnConflict{
+		Columns: []string{"id"},
+		Merge: map[string]MergeStrategy{
+			"hits":       MergeAdd,
+			"name":       MergeKeepExisting,
+			"updated_at": MergeGreatest,
+		},
+		OnlyIfChanged: true,
+	}
+	got, err := conflict.toSQL(identQuoter{}, `"stats"`, []string{"id", "hits", "name", "note", "updated_at"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `ON CONFLICT ("id") DO UPDATE SET ` +
+		`"hits" = COALESCE("stats"."hits", 0) + EXCLUDED."hits", ` +
+		`"name" = COALESCE("stats"."name", EXCLUDED."name"), ` +
+		`"note" = EXCLUDED."note", ` +
+		`"updated_at" = GREATEST("stats"."updated_at", EXCLUDED."updated_at") ` +
+		`WHERE ("stats"."hits", "stats"."name", "stats"."note", "stats"."updated_at") IS DISTINCT FROM ` +
+		`(COALESCE("stats"."hits", 0) + EXCLUDED."hits", COALESCE("stats"."name", EXCLUDED."name"), EXCLUDED."note", GREATEST("stats"."updated_at", EXCLUDED."updated_at"))`
+	if got != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestReturning(t *testing.T) {
+	clause, err := pg.Returning("id", "created_at").(*Postgres).returningClause()
+	if err != nil || clause != `RETURNING "id", "created_at"` {
+		t.Fatalf("unexpected clause %s (%v)", clause, err)
+	}
+	if pg.(*Postgres).returning != nil {
+		t.Fatal("Returning must not change the handle it is called on")
+	}
+
+	type Item struct {
+		ID     int64  `db:"id,readonly"`
+		Name   string `db:"name"`
+		Status string `db:"status,omitempty"`
+	}
+
+	ctx := context.Background()
+	err = pg.WithTx(ctx, nil, func(tx IDatabase) error {
+		if _, err := tx.Execute("CREATE TEMP TABLE godal_returning (id serial PRIMARY KEY, name text, status text DEFAULT 'new') ON COMMIT DROP", nil); err != nil {
+			return err
+		}
+
+		item := &Item{Name: "a"}
+		if _, err := tx.Returning().CreateWithStructContext(ctx, "godal_returning", item); err != nil {
+			return err
+		}
+		if item.ID == 0 || item.Status != "new" {
+			t.Errorf("expected the generated columns in the struct, got %+v", item)
+		}
+
+		rows, err := tx.Returning("id").UpdateContext(ctx, "godal_returning", map[string]interface{}{"status": "done"}, Where{"name": "a"})
+		if err != nil {
+			return err
+		}
+		if updated := rows.([]map[string]interface{}); len(updated) != 1 || updated[0]["id"] != item.ID {
+			t.Errorf("unexpected updated rows %v", updated)
+		}
+
+		rs, err := tx.Returning(`"godal_returning"."id"`).Writer().DeleteContext(ctx, "godal_returning", Where{"name": "a"})
+		if err != nil {
+			return err
+		}
+		if len(rs.Rows) != 1 || !reflect.DeepEqual(rs.IDs, []interface{}{item.ID}) {
+			t.Errorf("expected the ids of the qualified column, got %v", rs.IDs)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNotConnected) {
+		t.Skip("database is not available")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteResult(t *testing.T) {
+	var rs sql.Result = &WriteResult{rowsAffected: 2}
+	if n, err := rs.RowsAffected(); err != nil || n != 2 {
+		t.Fatalf("expected 2 affected rows, got %d (%v)", n, err)
+	}
+	if _, err := rs.LastInsertId(); err == nil {
+		t.Fatal("expected LastInsertId to be unsupported")
+	}
+
+	result := &WriteResult{}
+	result.add(&WriteResult{rowsAffected: 2, IDs: []interface{}{1, 2}, Statement: "first", Duration: time.Second})
+	result.add(&WriteResult{rowsAffected: 1, IDs: []interface{}{3}, Statement: "last", Duration: time.Second})
+	n, _ := result.RowsAffected()
+	if n != 3 || len(result.IDs) != 3 || result.Statement != "last" || result.Duration != 2*time.Second {
+		t.Fatalf("unexpected aggregated result %+v", result)
+	}
+
+	legacy, err := legacyResult(&WriteResult{rowsAffected: 1}, nil)
+	if n, _ := legacy.(sql.Result).RowsAffected(); err != nil || n != 1 {
+		t.Fatalf("expected the legacy result to be a sql.Result, got %v", legacy)
+	}
+	legacy, _ = legacyResult(&WriteResult{Rows: []map[string]interface{}{{"id": 1}}}, nil)
+	if rows, ok := legacy.([]map[string]interface{}); !ok || len(rows) != 1 {
+		t.Fatalf("expected the legacy result of a Returning handle to be the rows, got %v", legacy)
+	}
+	if legacy, err := legacyResult(nil, ErrNotConnected); legacy != nil || err != ErrNotConnected {
+		t.Fatalf("expected a nil legacy result, got %v (%v)", legacy, err)
+	}
+
+	executed, err := pg.Writer().Execute("SELECT 1", nil)
+	if err == ErrNotConnected {
+		t.Skip("database is not available")
+	}
+	if err != nil || executed.Statement != "SELECT 1" || executed.Duration <= 0 {
+		t.Fatalf("unexpected Execute result %+v (%v)", executed, err)
+	}
+}
+
+func TestUpdateStruct(t *testing.T) {
+	type Account struct {
+		ID        int64             `db:"id,pk"`
+		Name      string            `db:"name"`
+		Email     string            `db:"email,omitempty"`
+		Tags      []string          `db:"tags"`
+		Meta      map[string]string `db:"meta,json"`
+		Labels    pq.StringArray    `db:"labels"`
+		CreatedAt time.Time         `db:"created_at,no_update"`
+	}
+
+	fields, err := lookupFields(reflect.TypeOf(Account{}), []string{"Email", "name"})
+	if err != nil || !reflect.DeepEqual(columnsOf(fields), []string{"email", "name"}) || fields[0].omitEmpty {
+		t.Fatalf("unexpected fields %v (%v)", fields, err)
+	}
+	for _, name := range []string{"id", "created_at", "missing"} {
+		if _, err := lookupFields(reflect.TypeOf(Account{}), []string{name}); err == nil {
+			t.Errorf("expected an error for field %q", name)
+		}
+	}
+
+	account := &Account{ID: 1, Name: "a", Tags: []string{"x"}, Meta: map[string]string{"k": "v"}, Labels: pq.StringArray{"x"}, CreatedAt: time.Now()}
+	snapshot, err := TakeSnapshot(account)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := snapshot.Changed(account); len(changed) != 0 {
+		t.Fatalf("expected no change, got %v", changed)
+	}
+	account.Labels[0] = "y"
+	if changed, _ := snapshot.Changed(account); !reflect.DeepEqual(changed, []string{"labels"}) {
+		t.Fatalf("expected labels changed in place to change, got %v", changed)
+	}
+	account.Name = "b"
+	account.Meta["k"] = "w"
+	account.CreatedAt = account.CreatedAt.Add(time.Hour)
+	if changed, _ := snapshot.Changed(account); !reflect.DeepEqual(changed, []string{"name", "meta", "labels"}) {
+		t.Fatalf("expected name, meta and labels to change, got %v", changed)
+	}
+	if _, err := snapshot.Changed(&User{}); err == nil {
+		t.Fatal("expected an error for a snapshot of another type")
+	}
+	account.ID = 2
+	if _, err := snapshot.Changed(account); err == nil || !strings.Contains(err.Error(), `pk column "id"`) {
+		t.Fatalf("expected an error for a changed pk, got %v", err)
+	}
+	account.ID = 1
+
+	type NoKey struct {
+		Name string `db:"name"`
+	}
+	if _, err := pg.UpdateStruct("users", &NoKey{Name: "a"}); err == nil || !strings.Contains(err.Error(), "no pk field") {
+		t.Fatalf("expected a missing pk error, got %v", err)
+	}
+	if _, err := pg.Writer().UpdateStruct("users", &NoKey{Name: "a"}); err == nil || !strings.Contains(err.Error(), "no pk field") {
+		t.Fatalf("expected a missing pk error from the Writer, got %v", err)
+	}
+
+	ctx := context.Background()
+	err = pg.WithTx(ctx, nil, func(tx IDatabase) error {
+		if _, err := tx.Execute("CREATE TEMP TABLE godal_account (id int PRIMARY KEY, name text, email text, tags jsonb, meta jsonb, labels text[], created_at timestamptz) ON COMMIT DROP", nil); err != nil {
+			return err
+		}
+		if _, err := tx.CreateWithStructContext(ctx, "godal_account", account); err != nil {
+			return err
+		}
+
+		loaded, snapshot, err := GetWithSnapshot[*Account](ctx, tx, "SELECT * FROM godal_account WHERE id = $1", account.ID)
+		if err != nil {
+			return err
+		}
+		if changed, _ := snapshot.Changed(loaded); len(changed) != 0 {
+			t.Errorf("expected no change right after loading, got %v", changed)
+		}
+		account = loaded
+		account.Email = "a@example.com"
+		rs, err := tx.UpdateChangedContext(ctx, "godal_account", account, snapshot)
+		if err != nil {
+			return err
+		}
+		if n, _ := rs.RowsAffected(); n != 1 || !strings.Contains(rs.Statement, `SET "email"=$1`) {
+			t.Errorf("expected only email to be updated, got %d rows: %s", n, rs.Statement)
+		}
+
+		rs, err = tx.UpdateFieldsContext(ctx, "godal_account", account, "Name")
+		if err != nil {
+			return err
+		}
+		if n, _ := rs.RowsAffected(); n != 1 {
+			t.Errorf("expected 1 updated row, got %d", n)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrNotConnected) {
+		t.Skip("database is not available")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
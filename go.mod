@@ -1,8 +1,13 @@
 module github.com/nhsteck/godal
 
-go 1.13
+go 1.18
 
 require (
 	github.com/lib/pq v1.8.0
 	github.com/sirupsen/logrus v1.6.0
 )
+
+require (
+	github.com/konsorten/go-windows-terminal-sequences v1.0.3 // indirect
+	golang.org/x/sys v0.0.0-20190422165155-953cdadca894 // indirect
+)